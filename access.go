@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+)
+
+const (
+	permDownload = "download"
+	permAlerts   = "alerts"
+	permAudit    = "audit"
+	permReload   = "reload"
+)
+
+// roleConfig grant read access to the logs of Nodes, entries are node keys (shard03), chains (beacon) or
+// glob patterns (shard*), and the extra Permissions
+type roleConfig struct {
+	Name        string
+	Nodes       []string
+	Permissions []string
+}
+
+type accessPolicy struct {
+	roles        map[string]*roleConfig
+	userRoles    map[string][]string
+	defaultRoles []string
+}
+
+func newAccessPolicy(cfg *authConfig) (*accessPolicy, error) {
+	ap := &accessPolicy{
+		roles:     make(map[string]*roleConfig),
+		userRoles: make(map[string][]string),
+	}
+	if cfg == nil {
+		return ap, nil
+	}
+	for i := range cfg.Roles {
+		role := &cfg.Roles[i]
+		for _, perm := range role.Permissions {
+			if perm != permDownload && perm != permAlerts && perm != permAudit && perm != permReload {
+				return nil, fmt.Errorf("role %v has unknown permission %v", role.Name, perm)
+			}
+		}
+		ap.roles[role.Name] = role
+	}
+	for user, roles := range cfg.UserRoles {
+		for _, name := range roles {
+			if _, ok := ap.roles[name]; !ok {
+				return nil, fmt.Errorf("user %v has unknown role %v", user, name)
+			}
+		}
+		ap.userRoles[user] = roles
+	}
+	ap.defaultRoles = cfg.DefaultRoles
+	return ap, nil
+}
+
+// rolesOf return the roles of a user, nil when no role is configured at all so every user keep full access
+func (ap *accessPolicy) rolesOf(user string) []*roleConfig {
+	if len(ap.roles) == 0 {
+		return nil
+	}
+	names, ok := ap.userRoles[user]
+	if !ok {
+		names = ap.defaultRoles
+	}
+	roles := []*roleConfig{}
+	for _, name := range names {
+		if role, ok := ap.roles[name]; ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+func isChain(name string) bool {
+	for _, chain := range chainList() {
+		if chain == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeChain return the chain of a node key, the key is the chain name followed by the node number so it is
+// looked up in the topology rather than cut by prefix (shard10 is not a node of shard1 with 8 nodes per shard)
+func nodeChain(node string) (string, bool) {
+	for _, ref := range nodeList() {
+		if ref.chain+strconv.Itoa(ref.number) == node {
+			return ref.chain, true
+		}
+	}
+	return "", false
+}
+
+func (role *roleConfig) readsNode(node string) bool {
+	for _, entry := range role.Nodes {
+		if entry == "*" || entry == node {
+			return true
+		}
+		if isChain(entry) {
+			if chain, ok := nodeChain(node); ok && chain == entry {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(entry, node); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (role *roleConfig) has(perm string) bool {
+	for _, p := range role.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// canReadNode tell whether the identity can read the logs of node, a nil identity (auth disabled) or an
+// identity without roles (roles not configured) is unrestricted
+func (identity *Identity) canReadNode(node string) bool {
+	if identity == nil || identity.roles == nil {
+		return true
+	}
+	for _, role := range identity.roles {
+		if role.readsNode(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// canReadChain tell whether the identity can read at least a node of chain
+func (identity *Identity) canReadChain(chain string) bool {
+	for _, node := range nodeList() {
+		if node.chain == chain && identity.canReadNode(node.chain+strconv.Itoa(node.number)) {
+			return true
+		}
+	}
+	return false
+}
+
+// canReadWholeChain tell whether the identity can read every node of chain, it is required to act on the
+// alerts of a whole chain
+func (identity *Identity) canReadWholeChain(chain string) bool {
+	for _, node := range nodeList() {
+		if node.chain == chain && !identity.canReadNode(node.chain+strconv.Itoa(node.number)) {
+			return false
+		}
+	}
+	return true
+}
+
+// canReadAlert check the node of a node alert or the chain of a chain alert
+func (identity *Identity) canReadAlert(chain, node string) bool {
+	if node != "" {
+		return identity.canReadNode(node)
+	}
+	if chain != "" {
+		return identity.canReadChain(chain)
+	}
+	return true
+}
+
+func (identity *Identity) hasPermission(perm string) bool {
+	if identity == nil || identity.roles == nil {
+		return true
+	}
+	for _, role := range identity.roles {
+		if role.has(perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusFilter only let through the status of the nodes the identity can read
+func (identity *Identity) statusFilter() func(message []byte) bool {
+	if identity == nil || identity.roles == nil {
+		return nil
+	}
+	return func(message []byte) bool {
+		status := struct {
+			Node  int
+			Chain string
+		}{}
+		if err := json.Unmarshal(message, &status); err != nil {
+			return false
+		}
+		return identity.canReadNode(status.Chain + strconv.Itoa(status.Node))
+	}
+}
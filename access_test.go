@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestRoleReadsNodeOfChain(t *testing.T) {
+	withTopology(t, nodesConfig{BeaconNodes: 4, Shards: 12, NodesPerShard: 4})
+	role := &roleConfig{Name: "shard1", Nodes: []string{"shard1"}}
+	cases := map[string]bool{
+		"shard10":  true,
+		"shard13":  true,
+		"shard14":  false,
+		"shard100": false,
+		"shard110": false,
+		"shard2":   false,
+		"beacon1":  false,
+	}
+	for node, expect := range cases {
+		if role.readsNode(node) != expect {
+			t.Errorf("role shard1 reads %v: %v, expect %v", node, !expect, expect)
+		}
+	}
+	if !(&roleConfig{Nodes: []string{"shard1*"}}).readsNode("shard110") {
+		t.Error("glob entry not matched")
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	alertRenotifyInterval = time.Hour
+
+	alertNodeBehind     = "node-behind"
+	alertStoppedLogging = "stopped-logging"
+	alertRoundStall     = "round-stall"
+)
+
+type alertKey struct {
+	Chain     string
+	Node      string
+	Condition string
+}
+
+type Alert struct {
+	ID           int
+	Chain        string
+	Node         string
+	Condition    string
+	Severity     string
+	Message      string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	LastNotified time.Time
+	Acknowledged bool
+	AckTime      time.Time
+}
+
+// AlertSilence mute the alerts of a node or a whole chain until a deadline
+type AlertSilence struct {
+	ID     int
+	Chain  string
+	Node   string
+	Until  time.Time
+	Reason string
+}
+
+// alertManager keep the opened alerts, history is optional
+type alertManager struct {
+	lck           sync.Mutex
+	alerts        map[alertKey]*Alert
+	silences      []*AlertSilence
+	nextSilenceID int
+	notify        func(chain, node, severity, text string)
+	history       *alertStore
+}
+
+func newAlertManager(notify func(chain, node, severity, text string), history *alertStore) *alertManager {
+	return &alertManager{
+		alerts:  make(map[alertKey]*Alert),
+		notify:  notify,
+		history: history,
+	}
+}
+
+// flushHistory write the pending last seen time of the opened alerts
+func (am *alertManager) flushHistory() {
+	if am.history == nil {
+		return
+	}
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	for _, alert := range am.alerts {
+		am.history.sync(alert)
+	}
+}
+
+func (am *alertManager) isSilenced(chain, node string) bool {
+	now := time.Now()
+	for _, s := range am.silences {
+		if now.After(s.Until) {
+			continue
+		}
+		if (s.Node != "" && s.Node == node) || (s.Node == "" && s.Chain == chain) {
+			return true
+		}
+	}
+	return false
+}
+
+// fire open an alert or refresh an already opened one, notification is sent once then every alertRenotifyInterval
+// unless the alert is acknowledged or silenced
+func (am *alertManager) fire(chain, node, condition, severity, message string) {
+	am.lck.Lock()
+	send := am.fireLocked(chain, node, condition, severity, message)
+	am.lck.Unlock()
+	// notify is called without the lock so a slow notification never block the alerts
+	if send {
+		am.notify(chain, node, severity, message)
+	}
+}
+
+// fireLocked update the alert, it return whether the notification is due
+func (am *alertManager) fireLocked(chain, node, condition, severity, message string) bool {
+	key := alertKey{Chain: chain, Node: node, Condition: condition}
+	now := time.Now()
+	alert, ok := am.alerts[key]
+	if !ok {
+		alert = &Alert{
+			Chain:     chain,
+			Node:      node,
+			Condition: condition,
+			FirstSeen: now,
+		}
+		am.alerts[key] = alert
+	}
+	alert.Severity = severity
+	alert.Message = message
+	alert.LastSeen = now
+	if am.history != nil {
+		if !ok {
+			alert.ID = am.history.open(alert)
+		} else {
+			am.history.update(alert)
+		}
+	}
+	if alert.Acknowledged || am.isSilenced(chain, node) {
+		return false
+	}
+	if now.Sub(alert.LastNotified) > alertRenotifyInterval {
+		alert.LastNotified = now
+		return true
+	}
+	return false
+}
+
+// resolve close an opened alert and send the recovery message if the alert was notified
+func (am *alertManager) resolve(chain, node, condition, message string) {
+	am.lck.Lock()
+	send := am.resolveLocked(chain, node, condition)
+	am.lck.Unlock()
+	if send {
+		am.notify(chain, node, SeverityInfo, message)
+	}
+}
+
+func (am *alertManager) resolveLocked(chain, node, condition string) bool {
+	key := alertKey{Chain: chain, Node: node, Condition: condition}
+	alert, ok := am.alerts[key]
+	if !ok {
+		return false
+	}
+	delete(am.alerts, key)
+	if am.history != nil {
+		am.history.resolve(alert, time.Now())
+	}
+	return !alert.LastNotified.IsZero() && !am.isSilenced(chain, node)
+}
+
+// dismiss close an opened alert without recovery message, for alerts which are informative only
+func (am *alertManager) dismiss(chain, node, condition string) {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	key := alertKey{Chain: chain, Node: node, Condition: condition}
+	if alert, ok := am.alerts[key]; ok {
+		delete(am.alerts, key)
+		if am.history != nil {
+			am.history.resolve(alert, time.Now())
+		}
+	}
+}
+
+// dismissMatching dismiss the opened alerts selected by match, for the alerts of nodes or rules removed from the config
+func (am *alertManager) dismissMatching(match func(chain, node, condition string) bool) {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	for key, alert := range am.alerts {
+		if !match(key.Chain, key.Node, key.Condition) {
+			continue
+		}
+		delete(am.alerts, key)
+		if am.history != nil {
+			am.history.resolve(alert, time.Now())
+		}
+	}
+}
+
+func (am *alertManager) acknowledge(chain, node, condition string) bool {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	found := false
+	for key, alert := range am.alerts {
+		if (chain != "" && key.Chain != chain) || (node != "" && key.Node != node) || (condition != "" && key.Condition != condition) {
+			continue
+		}
+		alert.Acknowledged = true
+		alert.AckTime = time.Now()
+		found = true
+	}
+	return found
+}
+
+func (am *alertManager) silence(chain, node string, duration time.Duration, reason string) *AlertSilence {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	am.nextSilenceID++
+	s := &AlertSilence{
+		ID:     am.nextSilenceID,
+		Chain:  chain,
+		Node:   node,
+		Until:  time.Now().Add(duration),
+		Reason: reason,
+	}
+	am.silences = append(am.silences, s)
+	return s
+}
+
+func (am *alertManager) unsilence(id int) bool {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	for i, s := range am.silences {
+		if s.ID == id {
+			am.silences = append(am.silences[:i], am.silences[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (am *alertManager) getSilences() []AlertSilence {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	result := []AlertSilence{}
+	var active []*AlertSilence
+	for _, s := range am.silences {
+		if time.Now().After(s.Until) {
+			continue
+		}
+		active = append(active, s)
+		result = append(result, *s)
+	}
+	am.silences = active
+	return result
+}
+
+func (am *alertManager) getOpenAlerts() []Alert {
+	am.lck.Lock()
+	defer am.lck.Unlock()
+	result := []Alert{}
+	for _, alert := range am.alerts {
+		result = append(result, *alert)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FirstSeen.Before(result[j].FirstSeen)
+	})
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	resultBytes, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultBytes)
+}
+
+// canManageAlerts tell whether the request can acknowledge or silence the alerts of a node, or of every node
+// of chain when node is empty
+func canManageAlerts(r *http.Request, chain, node string) bool {
+	identity := requestIdentity(r)
+	if !identity.hasPermission(permAlerts) {
+		return false
+	}
+	if node != "" {
+		return identity.canReadNode(node)
+	}
+	return identity.canReadWholeChain(chain)
+}
+
+func openAlertsHandler(am *alertManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := requestIdentity(r)
+		result := []Alert{}
+		for _, alert := range am.getOpenAlerts() {
+			if identity.canReadAlert(alert.Chain, alert.Node) {
+				result = append(result, alert)
+			}
+		}
+		writeJSON(w, result)
+	}
+}
+
+func ackAlertHandler(am *alertManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		chain, node := query.Get("chain"), query.Get("node")
+		if chain == "" && node == "" {
+			http.Error(w, "chain or node is required", http.StatusBadRequest)
+			return
+		}
+		if !canManageAlerts(r, chain, node) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if !am.acknowledge(chain, node, query.Get("condition")) {
+			http.Error(w, "Alert not exist", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func silenceAlertHandler(am *alertManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.Method {
+		case "GET":
+			identity := requestIdentity(r)
+			result := []AlertSilence{}
+			for _, s := range am.getSilences() {
+				if identity.canReadAlert(s.Chain, s.Node) {
+					result = append(result, s)
+				}
+			}
+			writeJSON(w, result)
+		case "POST":
+			chain, node := query.Get("chain"), query.Get("node")
+			if chain == "" && node == "" {
+				http.Error(w, "chain or node is required", http.StatusBadRequest)
+				return
+			}
+			if !canManageAlerts(r, chain, node) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			duration, err := time.ParseDuration(query.Get("duration"))
+			if err != nil || duration <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, am.silence(chain, node, duration, query.Get("reason")))
+		case "DELETE":
+			id, _ := strconv.Atoi(query.Get("id"))
+			for _, s := range am.getSilences() {
+				if s.ID == id && !canManageAlerts(r, s.Chain, s.Node) {
+					http.Error(w, "Forbidden", http.StatusForbidden)
+					return
+				}
+			}
+			if !am.unsilence(id) {
+				http.Error(w, "Silence not exist", http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
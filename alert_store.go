@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const alertHistoryFlushInterval = time.Minute
+
+// AlertRecord is the history entry of an alert, every change append the whole record to the store
+// and the latest line of an ID win when loading
+type AlertRecord struct {
+	ID              int
+	Chain           string
+	Node            string
+	Condition       string
+	Severity        string
+	Message         string
+	FirstSeen       time.Time
+	LastSeen        time.Time
+	Resolved        time.Time
+	ClosedByRestart bool `json:",omitempty"`
+}
+
+type alertStore struct {
+	lck     sync.RWMutex
+	file    *os.File
+	records []*AlertRecord
+	byID    map[int]*AlertRecord
+	lastID  int
+}
+
+func openAlertStore(path string) (*alertStore, error) {
+	store := &alertStore{
+		byID: make(map[int]*AlertRecord),
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		record := &AlertRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			continue
+		}
+		if old, ok := store.byID[record.ID]; ok {
+			*old = *record
+			continue
+		}
+		store.byID[record.ID] = record
+		store.records = append(store.records, record)
+		if record.ID > store.lastID {
+			store.lastID = record.ID
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	store.file = file
+	// alerts left open by the previous run can't be resolved anymore
+	for _, record := range store.records {
+		if record.Resolved.IsZero() {
+			record.Resolved = record.LastSeen
+			record.ClosedByRestart = true
+			if err := store.write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return store, nil
+}
+
+func (store *alertStore) write(record *AlertRecord) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = store.file.Write(append(recordBytes, '\n'))
+	return err
+}
+
+// open create a new record and return its ID
+func (store *alertStore) open(alert *Alert) int {
+	store.lck.Lock()
+	defer store.lck.Unlock()
+	store.lastID++
+	record := &AlertRecord{
+		ID:        store.lastID,
+		Chain:     alert.Chain,
+		Node:      alert.Node,
+		Condition: alert.Condition,
+		Severity:  alert.Severity,
+		Message:   alert.Message,
+		FirstSeen: alert.FirstSeen,
+		LastSeen:  alert.LastSeen,
+	}
+	store.byID[record.ID] = record
+	store.records = append(store.records, record)
+	store.persist(record)
+	return record.ID
+}
+
+// update refresh the last seen time and message of a record, it is written to disk at most every alertHistoryFlushInterval
+func (store *alertStore) update(alert *Alert) {
+	store.lck.Lock()
+	defer store.lck.Unlock()
+	record, ok := store.byID[alert.ID]
+	if !ok {
+		return
+	}
+	flush := alert.LastSeen.Sub(record.LastSeen) > alertHistoryFlushInterval
+	record.Severity = alert.Severity
+	record.Message = alert.Message
+	if flush {
+		record.LastSeen = alert.LastSeen
+		store.persist(record)
+	}
+}
+
+func (store *alertStore) resolve(alert *Alert, resolved time.Time) {
+	store.lck.Lock()
+	defer store.lck.Unlock()
+	record, ok := store.byID[alert.ID]
+	if !ok {
+		return
+	}
+	record.LastSeen = alert.LastSeen
+	record.Resolved = resolved
+	store.persist(record)
+}
+
+// sync write the last seen time update held back by update
+func (store *alertStore) sync(alert *Alert) {
+	store.lck.Lock()
+	defer store.lck.Unlock()
+	record, ok := store.byID[alert.ID]
+	if !ok || !record.Resolved.IsZero() || !alert.LastSeen.After(record.LastSeen) {
+		return
+	}
+	record.LastSeen = alert.LastSeen
+	store.persist(record)
+}
+
+func (store *alertStore) close() error {
+	store.lck.Lock()
+	defer store.lck.Unlock()
+	return store.file.Close()
+}
+
+func (store *alertStore) persist(record *AlertRecord) {
+	if err := store.write(record); err != nil {
+		log.Println("alert store:", err)
+	}
+}
+
+type alertFilter struct {
+	Chain     string
+	Node      string
+	Condition string
+	Severity  string
+	Since     time.Time
+	Until     time.Time
+	OpenOnly  bool
+	Limit     int
+	// Readable drop the records the requester can't read, before they count toward Limit
+	Readable func(chain, node string) bool
+}
+
+func (f *alertFilter) match(record *AlertRecord) bool {
+	if (f.Chain != "" && record.Chain != f.Chain) || (f.Node != "" && record.Node != f.Node) {
+		return false
+	}
+	if (f.Condition != "" && record.Condition != f.Condition) || (f.Severity != "" && record.Severity != f.Severity) {
+		return false
+	}
+	if f.OpenOnly && !record.Resolved.IsZero() {
+		return false
+	}
+	if !f.Since.IsZero() && record.LastSeen.Before(f.Since) && (record.Resolved.IsZero() || record.Resolved.Before(f.Since)) {
+		return false
+	}
+	if !f.Until.IsZero() && record.FirstSeen.After(f.Until) {
+		return false
+	}
+	if f.Readable != nil && !f.Readable(record.Chain, record.Node) {
+		return false
+	}
+	return true
+}
+
+// query return the matching records, newest first
+func (store *alertStore) query(filter alertFilter) []AlertRecord {
+	store.lck.RLock()
+	defer store.lck.RUnlock()
+	result := []AlertRecord{}
+	for i := len(store.records) - 1; i >= 0; i-- {
+		if !filter.match(store.records[i]) {
+			continue
+		}
+		result = append(result, *store.records[i])
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+	}
+	return result
+}
+
+func alertHistoryHandler(store *alertStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		filter := alertFilter{
+			Chain:     query.Get("chain"),
+			Node:      query.Get("node"),
+			Condition: query.Get("condition"),
+			Severity:  query.Get("severity"),
+			OpenOnly:  query.Get("open") == "true",
+		}
+		var err error
+		if since := query.Get("since"); since != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+				http.Error(w, "invalid since, expect RFC3339", http.StatusBadRequest)
+				return
+			}
+		}
+		if until := query.Get("until"); until != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+				http.Error(w, "invalid until, expect RFC3339", http.StatusBadRequest)
+				return
+			}
+		}
+		filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+		filter.Readable = requestIdentity(r).canReadAlert
+		writeJSON(w, store.query(filter))
+	}
+}
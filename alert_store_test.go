@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestAlertHistoryLimitAfterAccess check the limit count only the records the requester can read
+func TestAlertHistoryLimitAfterAccess(t *testing.T) {
+	withTopology(t, nodesConfig{BeaconNodes: 2, Shards: 2, NodesPerShard: 2})
+	store := &alertStore{byID: make(map[int]*AlertRecord)}
+	now := time.Now()
+	for i, node := range []string{"beacon0", "beacon1", "shard00", "shard01", "shard10", "shard11"} {
+		record := &AlertRecord{ID: i + 1, Chain: node[:len(node)-1], Node: node, Condition: "down", FirstSeen: now, LastSeen: now}
+		store.records = append(store.records, record)
+		store.byID[record.ID] = record
+	}
+	identity := &Identity{Name: "ops", roles: []*roleConfig{{Name: "beacon", Nodes: []string{"beacon"}}}}
+	r := httptest.NewRequest("GET", "/api/alerts/history?limit=2", nil)
+	r = r.WithContext(context.WithValue(r.Context(), identityCtxKey{}, identity))
+	w := httptest.NewRecorder()
+	alertHistoryHandler(store)(w, r)
+	var result []AlertRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 || result[0].Node != "beacon1" || result[1].Node != "beacon0" {
+		t.Errorf("history %+v, expect beacon1 and beacon0", result)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	auditMaxFileSize = 50 * 1024 * 1024
+	// auditMaxFiles is the number of rotated files kept besides the current one
+	auditMaxFiles = 5
+
+	auditStream    = "stream"
+	auditStreamEnd = "stream-end"
+	auditHeightLog = "heightlog"
+	auditDownload  = "download"
+)
+
+// AuditEntry record an access to the logs of a node
+type AuditEntry struct {
+	Time       time.Time
+	Identity   string
+	AuthMethod string `json:",omitempty"`
+	RemoteAddr string
+	Action     string
+	Node       string
+	Range      string `json:",omitempty"`
+	Bytes      int64
+}
+
+// auditLog append the entries to a jsonl file, the file is rotated to path.1 ... path.N once it reach maxSize
+type auditLog struct {
+	lck      sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	maxSize  int64
+	maxFiles int
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	al := &auditLog{path: path, maxSize: auditMaxFileSize, maxFiles: auditMaxFiles}
+	if err := al.open(); err != nil {
+		return nil, err
+	}
+	return al, nil
+}
+
+func (al *auditLog) open() error {
+	file, err := os.OpenFile(al.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	al.file = file
+	al.size = info.Size()
+	return nil
+}
+
+func (al *auditLog) rotatedPath(i int) string {
+	return al.path + "." + strconv.Itoa(i)
+}
+
+func (al *auditLog) rotate() error {
+	al.file.Close()
+	os.Remove(al.rotatedPath(al.maxFiles))
+	for i := al.maxFiles - 1; i >= 1; i-- {
+		os.Rename(al.rotatedPath(i), al.rotatedPath(i+1))
+	}
+	renameErr := os.Rename(al.path, al.rotatedPath(1))
+	// reopen even when the rename failed so the entries keep being written
+	if err := al.open(); err != nil {
+		al.file = nil
+		return err
+	}
+	return renameErr
+}
+
+func (al *auditLog) write(entry AuditEntry) {
+	if al == nil {
+		return
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	entryBytes = append(entryBytes, '\n')
+	al.lck.Lock()
+	defer al.lck.Unlock()
+	if al.file == nil {
+		if err := al.open(); err != nil {
+			log.Println("audit open error: ", err)
+			return
+		}
+	}
+	if al.size+int64(len(entryBytes)) > al.maxSize && al.size > 0 {
+		if err := al.rotate(); err != nil {
+			log.Println("audit rotate error: ", err)
+			if al.file == nil {
+				return
+			}
+		}
+	}
+	n, err := al.file.Write(entryBytes)
+	al.size += int64(n)
+	if err != nil {
+		log.Println("audit write error: ", err)
+	}
+}
+
+func (al *auditLog) close() error {
+	al.lck.Lock()
+	defer al.lck.Unlock()
+	if al.file == nil {
+		return nil
+	}
+	err := al.file.Close()
+	al.file = nil
+	return err
+}
+
+// record write an access of the request to the logs of node
+func (al *auditLog) record(r *http.Request, action, node, rng string, bytes int64) {
+	entry := AuditEntry{
+		Time:       time.Now(),
+		RemoteAddr: r.RemoteAddr,
+		Action:     action,
+		Node:       node,
+		Range:      rng,
+		Bytes:      bytes,
+	}
+	if identity := requestIdentity(r); identity != nil {
+		entry.Identity = identity.Name
+		entry.AuthMethod = identity.Method
+	}
+	al.write(entry)
+}
+
+type auditFilter struct {
+	Identity string
+	Node     string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
+
+func (f *auditFilter) match(entry *AuditEntry) bool {
+	if (f.Identity != "" && entry.Identity != f.Identity) || (f.Node != "" && entry.Node != f.Node) || (f.Action != "" && entry.Action != f.Action) {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// query return the matching entries, newest first, reading the rotated files too, it doesn't hold the lock
+// so a long query doesn't delay the writes
+func (al *auditLog) query(filter auditFilter) ([]AuditEntry, error) {
+	result := []AuditEntry{}
+	paths := []string{al.path}
+	for i := 1; i <= al.maxFiles; i++ {
+		paths = append(paths, al.rotatedPath(i))
+	}
+	for _, path := range paths {
+		entries, err := readAuditFile(path, &filter)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", path, err)
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			result = append(result, entries[i])
+			if filter.Limit > 0 && len(result) >= filter.Limit {
+				return result, nil
+			}
+		}
+	}
+	return result, nil
+}
+
+func readAuditFile(path string, filter *auditFilter) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.match(&entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func auditHandler(al *auditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requestIdentity(r).hasPermission(permAudit) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		query := r.URL.Query()
+		filter := auditFilter{
+			Identity: query.Get("identity"),
+			Node:     query.Get("node"),
+			Action:   query.Get("action"),
+		}
+		var err error
+		if since := query.Get("since"); since != "" {
+			if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+				http.Error(w, "invalid since, expect RFC3339", http.StatusBadRequest)
+				return
+			}
+		}
+		if until := query.Get("until"); until != "" {
+			if filter.Until, err = time.Parse(time.RFC3339, until); err != nil {
+				http.Error(w, "invalid until, expect RFC3339", http.StatusBadRequest)
+				return
+			}
+		}
+		filter.Limit, _ = strconv.Atoi(query.Get("limit"))
+		entries, err := al.query(filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, entries)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const authCookieName = "logviewer_token"
+
+// authExemptPaths are served without credentials so the probes of orchestrators don't need any
+var authExemptPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type authConfig struct {
+	// Tokens map a static API token to the identity using it
+	Tokens map[string]string
+	// BasicUsersFile contain "user:bcrypt-hash" lines
+	BasicUsersFile string
+	OIDC           *oidcConfig
+	Roles          []roleConfig
+	// UserRoles map an identity name to its roles, users not listed get DefaultRoles
+	UserRoles    map[string][]string
+	DefaultRoles []string
+	// AllowedOrigins are the origins (https://ops.example.com) of the pages allowed to open the websockets and
+	// post to the API besides the pages of the service itself
+	AllowedOrigins []string
+}
+
+type Identity struct {
+	Name   string
+	Method string
+	roles  []*roleConfig
+}
+
+// Authenticator check the credentials of a request, it return a nil identity and no error when the request
+// carry no credentials it understands
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+type identityCtxKey struct{}
+
+type authService struct {
+	authenticators []Authenticator
+	basicEnabled   bool
+	policy         *accessPolicy
+	allowedOrigins map[string]bool
+}
+
+func newAuthService(cfg *authConfig) (*authService, error) {
+	policy, err := newAccessPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	as := &authService{policy: policy, allowedOrigins: make(map[string]bool)}
+	if cfg == nil {
+		return as, nil
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		as.allowedOrigins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	if len(cfg.Tokens) > 0 {
+		as.authenticators = append(as.authenticators, &tokenAuthenticator{tokens: cfg.Tokens})
+	}
+	if cfg.BasicUsersFile != "" {
+		basic, err := newBasicAuthenticator(cfg.BasicUsersFile)
+		if err != nil {
+			return nil, err
+		}
+		as.authenticators = append(as.authenticators, basic)
+		as.basicEnabled = true
+	}
+	if cfg.OIDC != nil {
+		as.authenticators = append(as.authenticators, newOIDCAuthenticator(*cfg.OIDC))
+	}
+	return as, nil
+}
+
+// addAuthenticator put authenticator before the configured ones
+func (as *authService) addAuthenticator(authenticator Authenticator) {
+	as.authenticators = append([]Authenticator{authenticator}, as.authenticators...)
+}
+
+func (as *authService) enabled() bool {
+	return len(as.authenticators) > 0
+}
+
+// requestToken return the token of a request from the Authorization header, the token query parameter or
+// the auth cookie, browsers can't set headers on websockets so the UI use the last two
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(authCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func (as *authService) authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range as.authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			identity.roles = as.policy.rolesOf(identity.Name)
+			return identity, nil
+		}
+	}
+	return nil, errInvalidCredentials
+}
+
+// require reject the requests without valid credentials and put the identity of the others in their context
+func (as *authService) require(next http.Handler) http.Handler {
+	if !as.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity, err := as.authenticate(r)
+		if err != nil {
+			if as.basicEnabled {
+				w.Header().Set("WWW-Authenticate", `Basic realm="logviewer"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// keep the token of the query in a cookie so the UI requests that follow carry it
+		if token := r.URL.Query().Get("token"); token != "" {
+			http.SetCookie(w, &http.Cookie{Name: authCookieName, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode, Secure: r.TLS != nil})
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityCtxKey{}, identity)))
+	})
+}
+
+// originAllowed tell whether a request come from the pages of the service or an allowed origin, browsers send
+// the basic credentials they cached with the requests of any page so the origin of the page has to be checked.
+// A request without Origin doesn't come from a cross origin page
+func (as *authService) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return as.allowedOrigins[strings.ToLower(origin)]
+}
+
+// checkOrigin reject the requests changing the state of the service that come from a foreign page
+func (as *authService) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" && r.Method != "OPTIONS" && !as.originAllowed(r) {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loggedURL return the url of a request without the token query parameter, for the logs
+func loggedURL(r *http.Request) string {
+	query := r.URL.Query()
+	if query.Get("token") == "" {
+		return r.URL.String()
+	}
+	query.Set("token", "redacted")
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// requestIdentity return the identity authenticated for the request, nil when auth is disabled
+func requestIdentity(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(identityCtxKey{}).(*Identity)
+	return identity
+}
+
+type tokenAuthenticator struct {
+	tokens map[string]string
+}
+
+func (ta *tokenAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	for t, name := range ta.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return &Identity{Name: name, Method: "token"}, nil
+		}
+	}
+	// the token may be an OIDC one
+	return nil, nil
+}
+
+type basicAuthenticator struct {
+	users map[string][]byte
+}
+
+func newBasicAuthenticator(path string) (*basicAuthenticator, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ba := &basicAuthenticator{users: make(map[string][]byte)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			log.Printf("invalid line in %v, expect user:bcrypt-hash\n", path)
+			continue
+		}
+		ba.users[parts[0]] = []byte(parts[1])
+	}
+	return ba, scanner.Err()
+}
+
+func (ba *basicAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	hash, ok := ba.users[user]
+	if !ok {
+		return nil, errInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return nil, errInvalidCredentials
+	}
+	return &Identity{Name: user, Method: "basic"}, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// blockTimingWindow is how many latest heights of a chain are used for the statistics
+const blockTimingWindow = 100
+
+type blockStart struct {
+	startAt  time.Time
+	timeslot int
+}
+
+type blockTimingTracker struct {
+	lck    sync.RWMutex
+	chains map[string]map[int]*blockStart
+}
+
+type BlockTiming struct {
+	Height          int
+	StartAt         time.Time
+	Timeslot        int
+	Interval        float64
+	MissedTimeslots int
+}
+
+type BlockTimingStats struct {
+	Chain              string
+	Samples            int
+	LastInterval       float64
+	AvgInterval        float64
+	MinInterval        float64
+	MaxInterval        float64
+	MissedTimeslots    int
+	AvgMissedTimeslots float64
+	Blocks             []BlockTiming `json:",omitempty"`
+}
+
+func newBlockTimingTracker() *blockTimingTracker {
+	return &blockTimingTracker{
+		chains: make(map[string]map[int]*blockStart),
+	}
+}
+
+// record keep the earliest time and timeslot any node of the chain started a height
+func (bt *blockTimingTracker) record(chain string, height int, startAt time.Time, timeslot int) {
+	bt.lck.Lock()
+	defer bt.lck.Unlock()
+	heights, ok := bt.chains[chain]
+	if !ok {
+		heights = make(map[int]*blockStart)
+		bt.chains[chain] = heights
+	}
+	start, ok := heights[height]
+	if !ok {
+		heights[height] = &blockStart{startAt: startAt, timeslot: timeslot}
+		for h := range heights {
+			if h <= height-blockTimingWindow {
+				delete(heights, h)
+			}
+		}
+		return
+	}
+	if startAt.Before(start.startAt) {
+		start.startAt = startAt
+	}
+	if timeslot < start.timeslot {
+		start.timeslot = timeslot
+	}
+}
+
+// stats compute the intervals between consecutive heights, the interval of a height is the time until the next one started
+func (bt *blockTimingTracker) stats(chain string, withBlocks bool) BlockTimingStats {
+	bt.lck.RLock()
+	defer bt.lck.RUnlock()
+	result := BlockTimingStats{Chain: chain}
+	heights := bt.chains[chain]
+	var sorted []int
+	for h := range heights {
+		sorted = append(sorted, h)
+	}
+	sort.Ints(sorted)
+	var totalInterval float64
+	for i, h := range sorted {
+		block := BlockTiming{Height: h, StartAt: heights[h].startAt, Timeslot: heights[h].timeslot}
+		if i+1 < len(sorted) && sorted[i+1] == h+1 {
+			next := heights[h+1]
+			block.Interval = next.startAt.Sub(block.StartAt).Seconds()
+			if next.timeslot > block.Timeslot+1 {
+				block.MissedTimeslots = next.timeslot - block.Timeslot - 1
+			}
+			if result.Samples == 0 || block.Interval < result.MinInterval {
+				result.MinInterval = block.Interval
+			}
+			if block.Interval > result.MaxInterval {
+				result.MaxInterval = block.Interval
+			}
+			result.Samples++
+			result.LastInterval = block.Interval
+			result.MissedTimeslots += block.MissedTimeslots
+			totalInterval += block.Interval
+		}
+		if withBlocks {
+			result.Blocks = append(result.Blocks, block)
+		}
+	}
+	if result.Samples > 0 {
+		result.AvgInterval = totalInterval / float64(result.Samples)
+		result.AvgMissedTimeslots = float64(result.MissedTimeslots) / float64(result.Samples)
+	}
+	return result
+}
+
+func blockTimingHandler(bt *blockTimingTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := requestIdentity(r)
+		chain := r.URL.Query().Get("chain")
+		if chain == "" {
+			var result []BlockTimingStats
+			for _, c := range chainList() {
+				if identity.canReadChain(c) {
+					result = append(result, bt.stats(c, false))
+				}
+			}
+			writeJSON(w, result)
+			return
+		}
+		if !identity.canReadChain(chain) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		writeJSON(w, bt.stats(chain, r.URL.Query().Get("blocks") == "true"))
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	roundStallCheckInterval = 30 * time.Second
+	roundStallTimeout       = 5 * time.Minute
+	roundStallMinRounds     = 3
+)
+
+type chainStallState struct {
+	height     int
+	since      time.Time
+	startRound int
+	maxRound   int
+	nodes      map[string]int
+	// rounds are the rounds seen on the nodes while the height didn't change, nodes can skip rounds
+	rounds    map[int]bool
+	isStalled bool
+}
+
+type ChainStatus struct {
+	Chain          string
+	BlockHeight    int
+	IsRoundStalled bool
+	StalledSince   time.Time
+	StallRounds    []int    `json:",omitempty"`
+	StallNodes     []string `json:",omitempty"`
+	BlockTiming    BlockTimingStats
+	// BeaconCrossLink is set for shards only
+	BeaconCrossLink *ShardCrossLink `json:",omitempty"`
+}
+
+type chainMonitor struct {
+	stallLck sync.RWMutex
+	stall    map[string]*chainStallState
+}
+
+func chainList() []string {
+	chains := []string{"beacon"}
+	for s := 0; s <= currentTopology().Shards-1; s++ {
+		chains = append(chains, "shard"+strconv.Itoa(s))
+	}
+	return chains
+}
+
+// getChainNodesRound return the round of every node of the chain that is currently at height
+func (lsrv *logTailService) getChainNodesRound(chain string, height int) map[string]int {
+	result := make(map[string]int)
+	lsrv.currentTailerLck.RLock()
+	defer lsrv.currentTailerLck.RUnlock()
+	for node, tailer := range lsrv.currentTailer {
+		if tailer.chain != chain {
+			continue
+		}
+		tailer.stateLck.RLock()
+		status := tailer.latestBlockProducingStatus
+		tailer.stateLck.RUnlock()
+		if int(status.BlockHeight) == height {
+			result[node] = status.Round
+		}
+	}
+	return result
+}
+
+func (lsrv *logTailService) watchRoundStall() {
+	t := time.NewTicker(roundStallCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-lsrv.stopping:
+			return
+		}
+		for _, chain := range chainList() {
+			lsrv.checkRoundStall(chain)
+		}
+	}
+}
+
+// checkRoundStall detect a chain which height stop advancing while its nodes keep increasing round
+func (lsrv *logTailService) checkRoundStall(chain string) {
+	height := lsrv.getBlockHeight(chain)
+	if height == 0 {
+		return
+	}
+	nodesRound := lsrv.getChainNodesRound(chain, height)
+	maxRound := 0
+	for _, round := range nodesRound {
+		if round > maxRound {
+			maxRound = round
+		}
+	}
+
+	lsrv.chainMonitor.stallLck.Lock()
+	defer lsrv.chainMonitor.stallLck.Unlock()
+	state, ok := lsrv.chainMonitor.stall[chain]
+	if !ok || state.height != height {
+		if ok && state.isStalled {
+			line := fmt.Sprintf("Chain %v resumed, height advanced from %v to %v 🎉", chain, state.height, height)
+			lsrv.alerts.resolve(chain, "", alertRoundStall, line)
+		}
+		state = &chainStallState{
+			height:     height,
+			since:      time.Now(),
+			startRound: maxRound,
+			maxRound:   maxRound,
+			nodes:      nodesRound,
+			rounds:     make(map[int]bool),
+		}
+		for _, round := range nodesRound {
+			state.rounds[round] = true
+		}
+		lsrv.chainMonitor.stall[chain] = state
+		return
+	}
+	if maxRound > state.maxRound {
+		state.maxRound = maxRound
+	}
+	for node, round := range nodesRound {
+		state.rounds[round] = true
+		if round > state.nodes[node] {
+			state.nodes[node] = round
+		}
+	}
+	if time.Since(state.since) < roundStallTimeout || state.maxRound-state.startRound < roundStallMinRounds {
+		return
+	}
+	state.isStalled = true
+	line := fmt.Sprintf("Chain %v stuck at height %v, round %v -> %v on nodes %v 😱", chain, state.height, state.startRound, state.maxRound, strings.Join(state.nodeList(), ", "))
+	lsrv.alerts.fire(chain, "", alertRoundStall, SeverityCritical, line)
+}
+
+func (state *chainStallState) nodeList() []string {
+	var nodes []string
+	for node := range state.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func (state *chainStallState) roundList() []int {
+	var rounds []int
+	for round := range state.rounds {
+		rounds = append(rounds, round)
+	}
+	sort.Ints(rounds)
+	return rounds
+}
+
+func (lsrv *logTailService) GetChainsStatus() []ChainStatus {
+	var result []ChainStatus
+	lsrv.chainMonitor.stallLck.RLock()
+	defer lsrv.chainMonitor.stallLck.RUnlock()
+	for _, chain := range chainList() {
+		status := ChainStatus{
+			Chain:       chain,
+			BlockHeight: lsrv.getBlockHeight(chain),
+			BlockTiming: lsrv.blockTiming.stats(chain, false),
+		}
+		if strings.HasPrefix(chain, "shard") {
+			shardID, _ := strconv.Atoi(strings.TrimPrefix(chain, "shard"))
+			crossLink := lsrv.GetShardCrossLink(shardID)
+			status.BeaconCrossLink = &crossLink
+		}
+		if state, ok := lsrv.chainMonitor.stall[chain]; ok && state.isStalled {
+			status.IsRoundStalled = true
+			status.StalledSince = state.since
+			status.StallRounds = state.roundList()
+			status.StallNodes = state.nodeList()
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+func chainStatusHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := requestIdentity(r)
+		result := []ChainStatus{}
+		for _, status := range lsrv.GetChainsStatus() {
+			if identity.canReadChain(status.Chain) {
+				result = append(result, status)
+			}
+		}
+		statusBytes, err := json.Marshal(result)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(statusBytes)
+	}
+}
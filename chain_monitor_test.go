@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRoundStallObservedRounds check a stall report the rounds the nodes went through, not every round between
+// the first and the last
+func TestRoundStallObservedRounds(t *testing.T) {
+	lsrv, fired := newTestService()
+	lsrv.updateBlockHeight("beacon", 10)
+	tailers := map[string]*logTail{"beacon0": {chain: "beacon"}, "beacon1": {chain: "beacon"}}
+	for node, tailer := range tailers {
+		lsrv.currentTailer[node] = tailer
+	}
+	setRounds := func(rounds map[string]int) {
+		for node, round := range rounds {
+			tailers[node].latestBlockProducingStatus = BlockProducingStatus{BlockHeight: 10, Round: round}
+		}
+	}
+	setRounds(map[string]int{"beacon0": 1, "beacon1": 1})
+	lsrv.checkRoundStall("beacon")
+	setRounds(map[string]int{"beacon0": 4, "beacon1": 3})
+	lsrv.checkRoundStall("beacon")
+	lsrv.chainMonitor.stall["beacon"].since = time.Now().Add(-2 * roundStallTimeout)
+	setRounds(map[string]int{"beacon0": 7, "beacon1": 7})
+	lsrv.checkRoundStall("beacon")
+	if len(*fired) != 1 || !strings.Contains((*fired)[0], "stuck at height 10") {
+		t.Fatalf("alerts %v", *fired)
+	}
+	status := lsrv.GetChainsStatus()[0]
+	if !status.IsRoundStalled || !reflect.DeepEqual(status.StallRounds, []int{1, 3, 4, 7}) {
+		t.Errorf("stall rounds %v, expect [1 3 4 7]", status.StallRounds)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+type serviceConfig struct {
+	Notifiers []notifierConfig
+	Routes    []notifierRoute
+	Rules     []patternRuleConfig
+	// Redactions are applied to every line sent to clients, on top of the built-in ones
+	Redactions []redactionRuleConfig
+	// NewErrorBaseline is how long an error template stay known before it is reported as new again
+	NewErrorBaseline string
+	Nodes            *nodesConfig
+	Auth             *authConfig
+}
+
+// nodesConfig is the size of the network followed, the fields left to zero keep the sizes of constants.go
+type nodesConfig struct {
+	BeaconNodes   int
+	Shards        int
+	NodesPerShard int
+}
+
+var defaultTopology = nodesConfig{BeaconNodes: NumberOfBeaconNode, Shards: NumberOfShards, NodesPerShard: NumberOfNodePerShard}
+
+var (
+	topologyLck sync.RWMutex
+	topology    = defaultTopology
+)
+
+// currentTopology return the size of the network followed, it change when the config is reloaded
+func currentTopology() nodesConfig {
+	topologyLck.RLock()
+	defer topologyLck.RUnlock()
+	return topology
+}
+
+func setTopology(nodes nodesConfig) {
+	topologyLck.Lock()
+	topology = nodes
+	topologyLck.Unlock()
+}
+
+// loadConfig read the service config from a json file, an empty path return the default config
+func loadConfig(path string) (*serviceConfig, error) {
+	config := &serviceConfig{}
+	if path != "" {
+		configBytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(configBytes, config); err != nil {
+			return nil, err
+		}
+	}
+	config.setDefault()
+	return config, nil
+}
+
+func (cfg *serviceConfig) newErrorBaseline() (time.Duration, error) {
+	if cfg.NewErrorBaseline == "" {
+		return defaultNewErrorBaseline, nil
+	}
+	return time.ParseDuration(cfg.NewErrorBaseline)
+}
+
+func (cfg *serviceConfig) topology() (nodesConfig, error) {
+	nodes := defaultTopology
+	if cfg.Nodes == nil {
+		return nodes, nil
+	}
+	if cfg.Nodes.BeaconNodes < 0 || cfg.Nodes.Shards < 0 || cfg.Nodes.NodesPerShard < 0 {
+		return nodes, fmt.Errorf("negative node count in %+v", *cfg.Nodes)
+	}
+	if cfg.Nodes.BeaconNodes > 0 {
+		nodes.BeaconNodes = cfg.Nodes.BeaconNodes
+	}
+	if cfg.Nodes.Shards > 0 {
+		nodes.Shards = cfg.Nodes.Shards
+	}
+	if cfg.Nodes.NodesPerShard > 0 {
+		nodes.NodesPerShard = cfg.Nodes.NodesPerShard
+	}
+	return nodes, nil
+}
+
+func (cfg *serviceConfig) setDefault() {
+	// keep the old behavior of posting everything to SLACKHOOK when no notifier is configured
+	if len(cfg.Notifiers) == 0 && os.Getenv("SLACKHOOK") != "" {
+		cfg.Notifiers = append(cfg.Notifiers, notifierConfig{
+			Name: "slack",
+			Type: "slack",
+			URL:  os.Getenv("SLACKHOOK"),
+		})
+		cfg.Routes = append(cfg.Routes, notifierRoute{
+			Notifiers: []string{"slack"},
+		})
+	}
+}
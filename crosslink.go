@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// crossLinkWindow is how many latest beacon heights keep the shard heights they include
+const crossLinkWindow = 200
+
+// crossLinkShardRe match the shard id and the shard height of a beacon line, written as "shard 1, height 2301" by
+// the log messages or "shardid:1 height:2301" by the %+v of a shard state, a range of blocks end at the third group
+var crossLinkShardRe = regexp.MustCompile(`shard(?:id)?[ :=]+(\d+)\D{1,40}?(?:height|block)[ :=]+(\d+)(?: to (?:height |block )?(\d+))?`)
+
+type crossLinkTracker struct {
+	lck sync.RWMutex
+	// beacon height -> shard id -> highest shard height included
+	beacon map[int]map[int]int
+	// shard id -> highest shard height confirmed by beacon
+	confirmed map[int]ShardConfirmation
+}
+
+type ShardConfirmation struct {
+	ShardID      int
+	ShardHeight  int
+	BeaconHeight int
+}
+
+type ShardCrossLink struct {
+	ShardID        int
+	ShardTip       int
+	ConfirmedBy    ShardConfirmation
+	BeaconLag      int
+	IsConfirmedAny bool
+}
+
+type BeaconInclusion struct {
+	BeaconHeight int
+	ShardHeights map[int]int
+}
+
+func newCrossLinkTracker() *crossLinkTracker {
+	return &crossLinkTracker{
+		beacon:    make(map[int]map[int]int),
+		confirmed: make(map[int]ShardConfirmation),
+	}
+}
+
+// isCrossLinkLine tell whether a lowercased beacon line reference the shard blocks the beacon block include
+func isCrossLinkLine(line string) bool {
+	return strings.Contains(line, "shard state") || strings.Contains(line, "shardstate") || strings.Contains(line, "shard to beacon")
+}
+
+// parseCrossLinks return the shard id -> shard height pairs of a cross reference line
+func parseCrossLinks(line string) map[int]int {
+	result := make(map[int]int)
+	shards := currentTopology().Shards
+	for _, match := range crossLinkShardRe.FindAllStringSubmatch(line, -1) {
+		shardID, _ := strconv.Atoi(match[1])
+		height, _ := strconv.Atoi(match[2])
+		if match[3] != "" {
+			height, _ = strconv.Atoi(match[3])
+		}
+		if shardID >= shards {
+			continue
+		}
+		if height > result[shardID] {
+			result[shardID] = height
+		}
+	}
+	return result
+}
+
+func (ct *crossLinkTracker) record(beaconHeight int, shardHeights map[int]int) {
+	ct.lck.Lock()
+	defer ct.lck.Unlock()
+	included, ok := ct.beacon[beaconHeight]
+	if !ok {
+		included = make(map[int]int)
+		ct.beacon[beaconHeight] = included
+		for h := range ct.beacon {
+			if h <= beaconHeight-crossLinkWindow {
+				delete(ct.beacon, h)
+			}
+		}
+	}
+	for shardID, height := range shardHeights {
+		if height > included[shardID] {
+			included[shardID] = height
+		}
+		if height > ct.confirmed[shardID].ShardHeight {
+			ct.confirmed[shardID] = ShardConfirmation{ShardID: shardID, ShardHeight: height, BeaconHeight: beaconHeight}
+		}
+	}
+}
+
+func (ct *crossLinkTracker) confirmation(shardID int) (ShardConfirmation, bool) {
+	ct.lck.RLock()
+	defer ct.lck.RUnlock()
+	confirmation, ok := ct.confirmed[shardID]
+	return confirmation, ok
+}
+
+func (ct *crossLinkTracker) inclusions() []BeaconInclusion {
+	ct.lck.RLock()
+	defer ct.lck.RUnlock()
+	result := []BeaconInclusion{}
+	for beaconHeight, included := range ct.beacon {
+		shardHeights := make(map[int]int)
+		for shardID, height := range included {
+			shardHeights[shardID] = height
+		}
+		result = append(result, BeaconInclusion{BeaconHeight: beaconHeight, ShardHeights: shardHeights})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].BeaconHeight > result[j].BeaconHeight
+	})
+	return result
+}
+
+// GetShardCrossLink compare the tip of a shard with the highest of its heights beacon confirmed
+func (lsrv *logTailService) GetShardCrossLink(shardID int) ShardCrossLink {
+	result := ShardCrossLink{
+		ShardID:  shardID,
+		ShardTip: lsrv.getBlockHeight("shard" + strconv.Itoa(shardID)),
+	}
+	result.ConfirmedBy, result.IsConfirmedAny = lsrv.crossLinks.confirmation(shardID)
+	if result.IsConfirmedAny && result.ShardTip > result.ConfirmedBy.ShardHeight {
+		result.BeaconLag = result.ShardTip - result.ConfirmedBy.ShardHeight
+	}
+	return result
+}
+
+func crossLinksHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		result := struct {
+			Shards  []ShardCrossLink
+			Beacons []BeaconInclusion `json:",omitempty"`
+		}{}
+		identity := requestIdentity(r)
+		for s := 0; s <= currentTopology().Shards-1; s++ {
+			if identity.canReadChain("shard" + strconv.Itoa(s)) {
+				result.Shards = append(result.Shards, lsrv.GetShardCrossLink(s))
+			}
+		}
+		if r.URL.Query().Get("beacons") == "true" && identity.canReadChain("beacon") {
+			result.Beacons = lsrv.crossLinks.inclusions()
+		}
+		writeJSON(w, result)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the lines are in the format of the beacon node output, the parser is fed them lowercased as readLogLine do
+func TestParseCrossLinks(t *testing.T) {
+	withTopology(t, nodesConfig{BeaconNodes: 4, Shards: 8, NodesPerShard: 4})
+	cases := []struct {
+		name   string
+		line   string
+		expect map[int]int
+	}{
+		{
+			"shard state of a new beacon block",
+			"2026-10-19 10:00:03.412 beaconprocess.go:412 [INF] Blockchain log: BEACON | Beacon Block 1523011, Shard State Shard 0, Height 1498203, Shard 3, Height 1497650",
+			map[int]int{0: 1498203, 3: 1497650},
+		},
+		{
+			"shard state dump",
+			"2026-10-19 10:00:03.415 beaconproducer.go:289 [INF] Blockchain log: Beacon Producer/ ShardState map[1:[{ShardID:1 Height:1501120 Hash:3f2a}] 7:[{ShardID:7 Height:1499001 Hash:9bc1}]]",
+			map[int]int{1: 1501120, 7: 1499001},
+		},
+		{
+			"shard to beacon blocks from the pool",
+			"2026-10-19 10:00:02.900 beaconproducer.go:231 [INF] Blockchain log: Beacon Producer/ Got Shard To Beacon Block, Shard 5, Block 1502554 to Block 1502556",
+			map[int]int{5: 1502556},
+		},
+		{
+			"shard out of the topology",
+			"2026-10-19 10:00:03.412 beaconprocess.go:412 [INF] Blockchain log: BEACON | Beacon Block 1523011, Shard State Shard 12, Height 1498203",
+			map[int]int{},
+		},
+		{
+			"no shard height",
+			"2026-10-19 10:00:03.500 beaconprocess.go:530 [INF] Blockchain log: BEACON | Shard State of shard to beacon pool is empty",
+			map[int]int{},
+		},
+	}
+	for _, c := range cases {
+		line := strings.ToLower(c.line)
+		if !isCrossLinkLine(line) {
+			t.Errorf("%v: not detected", c.name)
+			continue
+		}
+		if result := parseCrossLinks(line); !reflect.DeepEqual(result, c.expect) {
+			t.Errorf("%v: %v, expect %v", c.name, result, c.expect)
+		}
+	}
+	consensus := strings.ToLower("2026-10-19 10:00:00.000 blsbft.go:331 [INF] Consensus log beacon ts: 163374021, listen block 1523012, round 1")
+	if isCrossLinkLine(consensus) {
+		t.Error("consensus line detected as a cross link")
+	}
+}
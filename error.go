@@ -46,7 +46,7 @@ type RPCError struct {
 	Message    string `json:"Message,omitempty"`
 	StackTrace string `json:"StackTrace"`
 
-	err error `json:"Err"`
+	err error
 }
 
 // Error returns a string describing the RPC error.  This satisifies the
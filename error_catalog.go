@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTopErrorsLimit = 20
+	// errorCatalogRetention is how long a template not seen again stay in the catalog, maxErrorTemplates bound
+	// the catalog when the errors don't normalize well (peer ids, quoted values)
+	errorCatalogRetention = 48 * time.Hour
+	maxErrorTemplates     = 10000
+)
+
+var (
+	errorLinePrefixRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+	errorIPRe         = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`)
+	errorHexRe        = regexp.MustCompile(`\b(0x)?[0-9a-f]{16,}\b`)
+	errorAddressRe    = regexp.MustCompile(`\b[1-9a-z]{40,}\b`)
+	errorNumberRe     = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+)
+
+// ErrorTemplate is a normalized error line with its statistic across every node
+type ErrorTemplate struct {
+	Fingerprint Hash
+	Template    string
+	Count       int
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Nodes       map[string]*ErrorTemplateNodeStat
+}
+
+type ErrorTemplateNodeStat struct {
+	Chain     string
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+type errorCatalog struct {
+	lck       sync.RWMutex
+	templates map[Hash]*ErrorTemplate
+}
+
+func newErrorCatalog() *errorCatalog {
+	return &errorCatalog{
+		templates: make(map[Hash]*ErrorTemplate),
+	}
+}
+
+// normalizeErrorLine strip the variable parts of an error line (time, numbers, hashes, addresses) to get its template
+func normalizeErrorLine(line string) string {
+	template := errorLinePrefixRe.ReplaceAllString(strings.ToLower(line), "")
+	template = errorIPRe.ReplaceAllString(template, "<ip>")
+	template = errorHexRe.ReplaceAllString(template, "<hash>")
+	template = errorAddressRe.ReplaceAllString(template, "<addr>")
+	template = errorNumberRe.ReplaceAllString(template, "<num>")
+	return strings.TrimSpace(template)
+}
+
+func errorFingerprint(template string) Hash {
+	return HashH([]byte(template))
+}
+
+// add record an error line of node and return its template
+func (ec *errorCatalog) add(chain, node string, line string, seen time.Time) *ErrorTemplate {
+	template := normalizeErrorLine(line)
+	fingerprint := errorFingerprint(template)
+	ec.lck.Lock()
+	defer ec.lck.Unlock()
+	tpl, ok := ec.templates[fingerprint]
+	if !ok {
+		tpl = &ErrorTemplate{
+			Fingerprint: fingerprint,
+			Template:    template,
+			FirstSeen:   seen,
+			Nodes:       make(map[string]*ErrorTemplateNodeStat),
+		}
+		ec.templates[fingerprint] = tpl
+	}
+	tpl.Count++
+	if seen.Before(tpl.FirstSeen) {
+		tpl.FirstSeen = seen
+	}
+	if seen.After(tpl.LastSeen) {
+		tpl.LastSeen = seen
+	}
+	stat, ok := tpl.Nodes[node]
+	if !ok {
+		stat = &ErrorTemplateNodeStat{Chain: chain, FirstSeen: seen}
+		tpl.Nodes[node] = stat
+	}
+	stat.Count++
+	if seen.Before(stat.FirstSeen) {
+		stat.FirstSeen = seen
+	}
+	if seen.After(stat.LastSeen) {
+		stat.LastSeen = seen
+	}
+	return tpl
+}
+
+// expire drop the templates not seen during errorCatalogRetention and the least recently seen ones above
+// maxErrorTemplates. The time of reference is the latest error seen so a replay of a past day keep its errors
+func (ec *errorCatalog) expire() int {
+	ec.lck.Lock()
+	defer ec.lck.Unlock()
+	var latest time.Time
+	for _, tpl := range ec.templates {
+		if tpl.LastSeen.After(latest) {
+			latest = tpl.LastSeen
+		}
+	}
+	expired := 0
+	for fingerprint, tpl := range ec.templates {
+		if latest.Sub(tpl.LastSeen) > errorCatalogRetention {
+			delete(ec.templates, fingerprint)
+			expired++
+		}
+	}
+	if len(ec.templates) <= maxErrorTemplates {
+		return expired
+	}
+	templates := make([]*ErrorTemplate, 0, len(ec.templates))
+	for _, tpl := range ec.templates {
+		templates = append(templates, tpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].LastSeen.Before(templates[j].LastSeen)
+	})
+	for _, tpl := range templates[:len(templates)-maxErrorTemplates] {
+		delete(ec.templates, tpl.Fingerprint)
+		expired++
+	}
+	return expired
+}
+
+// topErrors return the most frequent templates of the nodes selected by the filter, counts are restricted to those nodes
+func (ec *errorCatalog) topErrors(selectNode func(chain, node string) bool, limit int) []ErrorTemplate {
+	ec.lck.RLock()
+	defer ec.lck.RUnlock()
+	result := []ErrorTemplate{}
+	for _, tpl := range ec.templates {
+		view := ErrorTemplate{
+			Fingerprint: tpl.Fingerprint,
+			Template:    tpl.Template,
+			Nodes:       make(map[string]*ErrorTemplateNodeStat),
+		}
+		for node, stat := range tpl.Nodes {
+			if !selectNode(stat.Chain, node) {
+				continue
+			}
+			nodeStat := *stat
+			view.Nodes[node] = &nodeStat
+			view.Count += stat.Count
+			if view.FirstSeen.IsZero() || stat.FirstSeen.Before(view.FirstSeen) {
+				view.FirstSeen = stat.FirstSeen
+			}
+			if stat.LastSeen.After(view.LastSeen) {
+				view.LastSeen = stat.LastSeen
+			}
+		}
+		if view.Count > 0 {
+			result = append(result, view)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count == result[j].Count {
+			return result[i].LastSeen.After(result[j].LastSeen)
+		}
+		return result[i].Count > result[j].Count
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func topErrorsHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		node, chain := query.Get("node"), query.Get("chain")
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 {
+			limit = defaultTopErrorsLimit
+		}
+		identity := requestIdentity(r)
+		selectNode := func(c, n string) bool {
+			return (node == "" || n == node) && (chain == "" || c == chain) && identity.canReadNode(n)
+		}
+		result := lsrv.errorCatalog.topErrors(selectNode, limit)
+		rd := lsrv.currentRedactor()
+		for i := range result {
+			result[i].Template = rd.redact(result[i].Template)
+		}
+		writeJSON(w, result)
+	}
+}
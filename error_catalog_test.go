@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopErrorsRedacted(t *testing.T) {
+	rd, err := newRedactor([]redactionRuleConfig{{Name: "session", Pattern: `session-[a-z]+`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsrv, fired := newTestService()
+	lsrv.redactor = rd
+	line := "2026-10-19 10:00:00.000 peer.go:1 [ERR] login failed for session-abcdef"
+	lsrv.errorCatalog.add("beacon", "beacon0", line, time.Now())
+	lsrv.reportNewErrors([]NewErrorEvent{{Chain: "beacon", Node: "beacon0", Template: normalizeErrorLine(line)}})
+
+	w := httptest.NewRecorder()
+	topErrorsHandler(lsrv)(w, httptest.NewRequest("GET", "/api/toperrors", nil))
+	var result []ErrorTemplate
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || len(result) != 1 {
+		t.Fatalf("toperrors %v: %v", w.Body.String(), err)
+	}
+	if strings.Contains(result[0].Template, "abcdef") {
+		t.Errorf("template not redacted: %v", result[0].Template)
+	}
+	if len(*fired) != 1 || strings.Contains((*fired)[0], "abcdef") {
+		t.Errorf("new error alert not redacted: %v", *fired)
+	}
+}
+
+func TestErrorCatalogExpire(t *testing.T) {
+	ec := newErrorCatalog()
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	ec.add("beacon", "beacon0", "[ERR] peer QmOld dropped", now.Add(-errorCatalogRetention-time.Minute))
+	ec.add("beacon", "beacon0", "[ERR] peer QmRecent dropped", now.Add(-time.Hour))
+	ec.add("beacon", "beacon0", "[ERR] peer QmLatest dropped", now)
+	if expired := ec.expire(); expired != 1 || len(ec.templates) != 2 {
+		t.Errorf("%v expired, %v kept, expect 1 and 2", expired, len(ec.templates))
+	}
+
+	ec = newErrorCatalog()
+	for i := 0; i < maxErrorTemplates+5; i++ {
+		// peer ids made of letters only are not normalized
+		id := []byte{'a' + byte(i%26), 'a' + byte(i/26%26), 'a' + byte(i/676%26)}
+		ec.add("beacon", "beacon0", "[ERR] peer Qm"+string(id)+" dropped", now.Add(time.Duration(i)*time.Second))
+	}
+	if expired := ec.expire(); expired != 5 || len(ec.templates) != maxErrorTemplates {
+		t.Errorf("%v expired, %v kept, expect 5 and %v", expired, len(ec.templates), maxErrorTemplates)
+	}
+	for _, tpl := range ec.templates {
+		if tpl.LastSeen.Before(now.Add(5 * time.Second)) {
+			t.Fatalf("template seen at %v kept over newer ones", tpl.LastSeen)
+		}
+	}
+}
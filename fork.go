@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	alertFork           = "fork"
+	alertVoteDivergence = "vote-divergence"
+	// forkTrackWindow is how many latest heights of a chain keep their hashes
+	forkTrackWindow = 200
+)
+
+type heightHashes struct {
+	// committed hash of each node
+	committed map[string]string
+	// round -> hash -> nodes that received votes for it
+	votes map[int]map[string][]string
+}
+
+type forkTracker struct {
+	lck    sync.RWMutex
+	chains map[string]map[int]*heightHashes
+}
+
+type HeightDivergence struct {
+	Chain     string
+	Height    int
+	Committed map[string][]string
+	Votes     map[int]map[string][]string `json:",omitempty"`
+}
+
+func newForkTracker() *forkTracker {
+	return &forkTracker{
+		chains: make(map[string]map[int]*heightHashes),
+	}
+}
+
+func (ft *forkTracker) getHeight(chain string, height int) *heightHashes {
+	heights, ok := ft.chains[chain]
+	if !ok {
+		heights = make(map[int]*heightHashes)
+		ft.chains[chain] = heights
+	}
+	hh, ok := heights[height]
+	if !ok {
+		hh = &heightHashes{
+			committed: make(map[string]string),
+			votes:     make(map[int]map[string][]string),
+		}
+		heights[height] = hh
+	}
+	return hh
+}
+
+// prune drop the heights out of the window and return them
+func (ft *forkTracker) prune(chain string, height int) []int {
+	var pruned []int
+	for h := range ft.chains[chain] {
+		if h <= height-forkTrackWindow {
+			delete(ft.chains[chain], h)
+			pruned = append(pruned, h)
+		}
+	}
+	return pruned
+}
+
+// recordVote save the hash a node received votes for and return the hashes of the round when nodes disagree
+func (ft *forkTracker) recordVote(chain, node string, height, round int, hash string) map[string][]string {
+	ft.lck.Lock()
+	defer ft.lck.Unlock()
+	hh := ft.getHeight(chain, height)
+	if _, ok := hh.votes[round]; !ok {
+		hh.votes[round] = make(map[string][]string)
+	}
+	for _, n := range hh.votes[round][hash] {
+		if n == node {
+			return nil
+		}
+	}
+	hh.votes[round][hash] = append(hh.votes[round][hash], node)
+	if len(hh.votes[round]) < 2 {
+		return nil
+	}
+	result := make(map[string][]string)
+	for h, nodes := range hh.votes[round] {
+		result[h] = append([]string{}, nodes...)
+		sort.Strings(result[h])
+	}
+	return result
+}
+
+// recordCommit save the hash a node committed at height and return the committed hashes when nodes disagree
+func (ft *forkTracker) recordCommit(chain, node string, height int, hash string) (map[string][]string, []int) {
+	ft.lck.Lock()
+	defer ft.lck.Unlock()
+	hh := ft.getHeight(chain, height)
+	hh.committed[node] = hash
+	pruned := ft.prune(chain, height)
+	committed := hh.committedByHash()
+	if len(committed) > 1 {
+		return committed, pruned
+	}
+	return nil, pruned
+}
+
+func (hh *heightHashes) committedByHash() map[string][]string {
+	result := make(map[string][]string)
+	for node, hash := range hh.committed {
+		result[hash] = append(result[hash], node)
+	}
+	for _, nodes := range result {
+		sort.Strings(nodes)
+	}
+	return result
+}
+
+// divergences list the heights where nodes committed different hashes, or received votes for different hashes
+// in the same round when withVotes is set
+func (ft *forkTracker) divergences(chain string, withVotes bool) []HeightDivergence {
+	ft.lck.RLock()
+	defer ft.lck.RUnlock()
+	result := []HeightDivergence{}
+	for c, heights := range ft.chains {
+		if chain != "" && c != chain {
+			continue
+		}
+		for height, hh := range heights {
+			d := HeightDivergence{Chain: c, Height: height, Committed: hh.committedByHash()}
+			diverged := len(d.Committed) > 1
+			if withVotes {
+				for round, hashes := range hh.votes {
+					if len(hashes) > 1 {
+						if d.Votes == nil {
+							d.Votes = make(map[int]map[string][]string)
+						}
+						d.Votes[round] = hashes
+						diverged = true
+					}
+				}
+			}
+			if diverged {
+				result = append(result, d)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Chain == result[j].Chain {
+			return result[i].Height > result[j].Height
+		}
+		return result[i].Chain < result[j].Chain
+	})
+	return result
+}
+
+// recordCommit alert when the nodes of a chain committed different blocks, the lines of the initial scan only
+// feed the tracker so a restart doesn't alert again on the forks already in the log
+func (lsrv *logTailService) recordCommit(chain, node string, height int, hash string, live bool) {
+	committed, pruned := lsrv.forkTracker.recordCommit(chain, node, height, hash)
+	for _, h := range pruned {
+		lsrv.alerts.dismiss(chain, "", alertFork+":"+strconv.Itoa(h))
+		lsrv.alerts.dismiss(chain, "", alertVoteDivergence+":"+strconv.Itoa(h))
+	}
+	if committed == nil || !live {
+		return
+	}
+	text := fmt.Sprintf("Chain %v fork at height %v, committed %v 🔱", chain, height, describeHashes(committed))
+	lsrv.alerts.fire(chain, "", alertFork+":"+strconv.Itoa(height), SeverityCritical, text)
+}
+
+// recordVote alert when the nodes of a chain received votes for different blocks in the same round, as recordCommit
+// only the live lines alert
+func (lsrv *logTailService) recordVote(chain, node string, height, round int, hash string, live bool) {
+	voted := lsrv.forkTracker.recordVote(chain, node, height, round, hash)
+	if voted == nil || !live {
+		return
+	}
+	text := fmt.Sprintf("Chain %v votes diverge at height %v round %v, voted %v 🔀", chain, height, round, describeHashes(voted))
+	lsrv.alerts.fire(chain, "", alertVoteDivergence+":"+strconv.Itoa(height), SeverityWarning, text)
+}
+
+func describeHashes(nodesByHash map[string][]string) string {
+	var parts []string
+	for hash, nodes := range nodesByHash {
+		parts = append(parts, fmt.Sprintf("%v by %v", hash, strings.Join(nodes, ", ")))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
+
+func forksHandler(ft *forkTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := requestIdentity(r)
+		result := []HeightDivergence{}
+		for _, divergence := range ft.divergences(r.URL.Query().Get("chain"), r.URL.Query().Get("votes") == "true") {
+			if identity.canReadChain(divergence.Chain) {
+				result = append(result, divergence)
+			}
+		}
+		writeJSON(w, result)
+	}
+}
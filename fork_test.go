@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func feedLines(l *logTail, lines ...string) {
+	for i, line := range lines {
+		l.readLogLine(line, i+2, true)
+	}
+}
+
+// TestCommitWithoutVotesKeepHash check that a commit line without hash at a height without votes doesn't
+// reuse the hash voted at the previous height
+func TestCommitWithoutVotesKeepHash(t *testing.T) {
+	lsrv, fired := newTestService()
+	nodes := []*logTail{
+		{chain: "beacon", nodeNumber: 0, logService: lsrv, heightsRecord: make(map[int]*heightRecord), isLive: true},
+		{chain: "beacon", nodeNumber: 1, logService: lsrv, heightsRecord: make(map[int]*heightRecord), isLive: true},
+	}
+	for _, l := range nodes {
+		feedLines(l,
+			"2026-10-19 10:00:00.000 blsbft.go:1 [INF] Consensus log beacon ts: 100, listen block 5, round 1",
+			"2026-10-19 10:00:06.000 blsbft.go:1 [INF] Consensus log beacon receive vote (3) for block aaaaaaaaaaaaaaaa from validator 2 key",
+			"2026-10-19 10:00:07.000 blsbft.go:1 [INF] Consensus log commit block",
+		)
+	}
+	feedLines(nodes[0],
+		"2026-10-19 10:00:10.000 blsbft.go:1 [INF] Consensus log beacon ts: 101, listen block 6, round 1",
+		"2026-10-19 10:00:17.000 blsbft.go:1 [INF] Consensus log commit block",
+	)
+	feedLines(nodes[1],
+		"2026-10-19 10:00:10.000 blsbft.go:1 [INF] Consensus log beacon ts: 101, listen block 6, round 1",
+		"2026-10-19 10:00:17.000 blsbft.go:1 [INF] Consensus log commit block bbbbbbbbbbbbbbbb",
+	)
+	if len(*fired) != 0 {
+		t.Errorf("unexpected alerts %v", *fired)
+	}
+}
+
+func TestVoteDivergenceAlert(t *testing.T) {
+	lsrv, fired := newTestService()
+	lsrv.recordVote("shard0", "shard00", 7, 1, "aaaaaaaaaaaaaaaa", true)
+	lsrv.recordVote("shard0", "shard01", 7, 1, "aaaaaaaaaaaaaaaa", true)
+	lsrv.recordVote("shard0", "shard02", 7, 2, "bbbbbbbbbbbbbbbb", true)
+	if len(*fired) != 0 {
+		t.Fatalf("votes of different rounds alerted: %v", *fired)
+	}
+	lsrv.recordVote("shard0", "shard02", 7, 1, "bbbbbbbbbbbbbbbb", true)
+	if len(*fired) != 1 || !strings.Contains((*fired)[0], "height 7 round 1") {
+		t.Errorf("expect a vote divergence alert, got %v", *fired)
+	}
+}
+
+// TestScanForkNotAlerted check the forks and vote divergences read by the initial scan are tracked without alerting
+func TestScanForkNotAlerted(t *testing.T) {
+	lsrv, fired := newTestService()
+	lsrv.recordVote("shard0", "shard00", 7, 1, "aaaaaaaaaaaaaaaa", false)
+	lsrv.recordVote("shard0", "shard01", 7, 1, "bbbbbbbbbbbbbbbb", false)
+	lsrv.recordCommit("shard0", "shard00", 7, "aaaaaaaaaaaaaaaa", false)
+	lsrv.recordCommit("shard0", "shard01", 7, "bbbbbbbbbbbbbbbb", false)
+	if len(*fired) != 0 {
+		t.Errorf("scanned lines alerted: %v", *fired)
+	}
+	if divergences := lsrv.forkTracker.divergences("shard0", true); len(divergences) != 1 {
+		t.Errorf("%v divergences tracked, expect 1", len(divergences))
+	}
+	lsrv.recordCommit("shard0", "shard02", 7, "bbbbbbbbbbbbbbbb", true)
+	if len(*fired) != 1 || !strings.Contains((*fired)[0], "fork at height 7") {
+		t.Errorf("expect a fork alert, got %v", *fired)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readiness track the initial scan of the tailers, the service is ready once every log file found at
+// startup has been scanned
+type readiness struct {
+	lck     sync.RWMutex
+	pending map[string]bool
+	scans   map[string]time.Duration
+}
+
+type ReadyStatus struct {
+	Ready   bool
+	Scanned int
+	Pending int
+}
+
+// expectInitialScans mark the nodes whose log file is already there, the others start when their file
+// appear and don't delay the readiness
+func (lsrv *logTailService) expectInitialScans(files []os.FileInfo, date string) {
+	pending := make(map[string]bool)
+	for _, node := range nodeList() {
+		filePrefix, fileSuffix := getLogFileNameOfDate(node.chain, node.number, date)
+		if getLogFileForFileList(filePrefix, fileSuffix, files) != nil {
+			pending[node.chain+strconv.Itoa(node.number)] = true
+		}
+	}
+	lsrv.readiness.lck.Lock()
+	lsrv.readiness.pending = pending
+	lsrv.readiness.lck.Unlock()
+}
+
+// recordInitialScan is called once per tailer when its first scan end, failed or not, so one unreadable file
+// doesn't keep the service unready
+func (lsrv *logTailService) recordInitialScan(node string, duration time.Duration) {
+	lsrv.readiness.lck.Lock()
+	defer lsrv.readiness.lck.Unlock()
+	if lsrv.readiness.scans == nil {
+		lsrv.readiness.scans = make(map[string]time.Duration)
+	}
+	delete(lsrv.readiness.pending, node)
+	if _, ok := lsrv.readiness.scans[node]; !ok {
+		lsrv.readiness.scans[node] = duration
+	}
+}
+
+// forgetInitialScan drop a node removed from the config
+func (lsrv *logTailService) forgetInitialScan(node string) {
+	lsrv.readiness.lck.Lock()
+	defer lsrv.readiness.lck.Unlock()
+	delete(lsrv.readiness.pending, node)
+	delete(lsrv.readiness.scans, node)
+}
+
+func (lsrv *logTailService) readyStatus() ReadyStatus {
+	lsrv.readiness.lck.RLock()
+	defer lsrv.readiness.lck.RUnlock()
+	return ReadyStatus{
+		Ready:   len(lsrv.readiness.pending) == 0,
+		Scanned: len(lsrv.readiness.scans),
+		Pending: len(lsrv.readiness.pending),
+	}
+}
+
+// healthzHandler answer as long as the service serve requests
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+func readyzHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := lsrv.readyStatus()
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		writeJSON(w, status)
+	}
+}
+
+// writeServiceMetrics write the metrics of the viewer itself
+func (lsrv *logTailService) writeServiceMetrics(buf *bytes.Buffer, identity *Identity) {
+	fmt.Fprintf(buf, "# HELP logviewer_goroutines Number of goroutines\n# TYPE logviewer_goroutines gauge\nlogviewer_goroutines %v\n", runtime.NumGoroutine())
+
+	hubs := map[string]*Hub{"status": lsrv.statusHub}
+	if lsrv.lHub != nil {
+		lsrv.lHub.hubsLck.RLock()
+		for node, hub := range lsrv.lHub.hubs {
+			if identity.canReadNode(node) {
+				hubs[node] = hub
+			}
+		}
+		lsrv.lHub.hubsLck.RUnlock()
+	}
+	var names []string
+	for name, hub := range hubs {
+		if hub != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	fmt.Fprintf(buf, "# HELP logviewer_websocket_clients Websocket clients connected per hub\n# TYPE logviewer_websocket_clients gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(buf, "logviewer_websocket_clients{hub=%q} %v\n", name, atomic.LoadInt64(&hubs[name].clientCount))
+	}
+	fmt.Fprintf(buf, "# HELP logviewer_websocket_dropped_total Messages dropped for slow websocket clients per hub\n# TYPE logviewer_websocket_dropped_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(buf, "logviewer_websocket_dropped_total{hub=%q} %v\n", name, atomic.LoadInt64(&hubs[name].dropped))
+	}
+
+	lsrv.readiness.lck.RLock()
+	var nodes []string
+	for node := range lsrv.readiness.scans {
+		if identity.canReadNode(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+	fmt.Fprintf(buf, "# HELP logviewer_index_build_seconds Duration of the initial scan building the heights record\n# TYPE logviewer_index_build_seconds gauge\n")
+	for _, node := range nodes {
+		fmt.Fprintf(buf, "logviewer_index_build_seconds{node=%q} %v\n", node, lsrv.readiness.scans[node].Seconds())
+	}
+	lsrv.readiness.lck.RUnlock()
+	fmt.Fprintf(buf, "# HELP logviewer_ready Whether every initial scan is done\n# TYPE logviewer_ready gauge\nlogviewer_ready %v\n", boolMetric(lsrv.readyStatus().Ready))
+
+	if notifier := lsrv.currentNotifier(); notifier != nil {
+		sent, failures := notifier.stats()
+		var notifiers []string
+		for name := range sent {
+			notifiers = append(notifiers, name)
+		}
+		sort.Strings(notifiers)
+		fmt.Fprintf(buf, "# HELP logviewer_notifications_sent_total Notification batches sent per notifier\n# TYPE logviewer_notifications_sent_total counter\n")
+		for _, name := range notifiers {
+			fmt.Fprintf(buf, "logviewer_notifications_sent_total{notifier=%q} %v\n", name, sent[name])
+		}
+		fmt.Fprintf(buf, "# HELP logviewer_notifications_failed_total Notification batches that failed per notifier\n# TYPE logviewer_notifications_failed_total counter\n")
+		for _, name := range notifiers {
+			fmt.Fprintf(buf, "logviewer_notifications_failed_total{notifier=%q} %v\n", name, failures[name])
+		}
+	}
+}
+
+func boolMetric(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	ingestShortWindow = 30 * time.Second
+	ingestLongWindow  = 10 * time.Minute
+
+	// a drop is reported when the short rate fall under this ratio of the long rate
+	ingestDropRatio = 0.2
+	// a flood is reported when the short rate exceed this ratio of the long rate
+	ingestFloodRatio = 5
+	// rates under this many lines/s are too low to tell an anomaly
+	ingestMinLinesRate = 0.5
+
+	alertIngestDrop  = "ingest-drop"
+	alertIngestFlood = "ingest-flood"
+)
+
+// ingestStats measure the lines and bytes a logTail read with a short and a long exponential moving average
+type ingestStats struct {
+	lck        sync.Mutex
+	lines      int64
+	bytes      int64
+	totalLines int64
+	totalBytes int64
+	lastLine   time.Time
+	lastSample time.Time
+	startTime  time.Time
+	linesShort float64
+	linesLong  float64
+	bytesShort float64
+	bytesLong  float64
+}
+
+type IngestStatus struct {
+	LinesPerSecShort float64
+	LinesPerSecLong  float64
+	BytesPerSecShort float64
+	BytesPerSecLong  float64
+	TotalLines       int64
+	TotalBytes       int64
+	SinceLastLine    float64
+}
+
+func (is *ingestStats) record(size int) {
+	is.lck.Lock()
+	is.lines++
+	is.bytes += int64(size)
+	is.totalLines++
+	is.totalBytes += int64(size)
+	is.lastLine = time.Now()
+	is.lck.Unlock()
+}
+
+func ewma(avg, rate float64, elapsed, window time.Duration) float64 {
+	alpha := 1 - math.Exp(-elapsed.Seconds()/window.Seconds())
+	return avg + alpha*(rate-avg)
+}
+
+// sample fold the counters gathered since the previous sample into the moving averages
+func (is *ingestStats) sample() {
+	is.lck.Lock()
+	defer is.lck.Unlock()
+	now := time.Now()
+	if is.lastSample.IsZero() {
+		is.lastSample = now
+		is.startTime = now
+		return
+	}
+	elapsed := now.Sub(is.lastSample)
+	if elapsed <= 0 {
+		return
+	}
+	linesRate := float64(is.lines) / elapsed.Seconds()
+	bytesRate := float64(is.bytes) / elapsed.Seconds()
+	is.linesShort = ewma(is.linesShort, linesRate, elapsed, ingestShortWindow)
+	is.linesLong = ewma(is.linesLong, linesRate, elapsed, ingestLongWindow)
+	is.bytesShort = ewma(is.bytesShort, bytesRate, elapsed, ingestShortWindow)
+	is.bytesLong = ewma(is.bytesLong, bytesRate, elapsed, ingestLongWindow)
+	is.lines, is.bytes = 0, 0
+	is.lastSample = now
+}
+
+func (is *ingestStats) status() IngestStatus {
+	is.lck.Lock()
+	defer is.lck.Unlock()
+	status := IngestStatus{
+		LinesPerSecShort: is.linesShort,
+		LinesPerSecLong:  is.linesLong,
+		BytesPerSecShort: is.bytesShort,
+		BytesPerSecLong:  is.bytesLong,
+		TotalLines:       is.totalLines,
+		TotalBytes:       is.totalBytes,
+	}
+	if !is.lastLine.IsZero() {
+		status.SinceLastLine = time.Since(is.lastLine).Seconds()
+	}
+	return status
+}
+
+// anomaly tell whether the short rate suddenly dropped or flooded compared to the long one
+func (is *ingestStats) anomaly() (drop bool, flood bool) {
+	is.lck.Lock()
+	defer is.lck.Unlock()
+	if is.startTime.IsZero() || time.Since(is.startTime) < ingestLongWindow {
+		return false, false
+	}
+	drop = is.linesLong >= ingestMinLinesRate && is.linesShort < is.linesLong*ingestDropRatio
+	flood = is.linesShort >= ingestMinLinesRate && is.linesShort > is.linesLong*ingestFloodRatio
+	return drop, flood
+}
+
+func (l *logTail) checkIngestAnomaly(node string) {
+	drop, flood := l.ingest.anomaly()
+	status := l.ingest.status()
+	alerts := l.logService.alerts
+	if drop {
+		alerts.fire(l.chain, node, alertIngestDrop, SeverityWarning, fmt.Sprintf("Node %v log rate dropped to %.2f lines/s (usual %.2f) 📉", node, status.LinesPerSecShort, status.LinesPerSecLong))
+	} else {
+		alerts.resolve(l.chain, node, alertIngestDrop, fmt.Sprintf("Node %v log rate back to %.2f lines/s 🎉", node, status.LinesPerSecShort))
+	}
+	if flood {
+		alerts.fire(l.chain, node, alertIngestFlood, SeverityWarning, fmt.Sprintf("Node %v log rate flooded to %.2f lines/s (usual %.2f) 📈", node, status.LinesPerSecShort, status.LinesPerSecLong))
+	} else {
+		alerts.resolve(l.chain, node, alertIngestFlood, fmt.Sprintf("Node %v log rate back to %.2f lines/s 🎉", node, status.LinesPerSecShort))
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"io"
+)
+
+const maxLogLineLength = 1024 * 1024
+
+// logLineReader read a log line by line like bufio.Scanner, the lines longer than maxLogLineLength are skipped
+// and returned empty so the line numbers stay the same and one huge line doesn't stop the reading
+type logLineReader struct {
+	reader  *bufio.Reader
+	line    string
+	skipped int
+	err     error
+}
+
+func newLogLineReader(r io.Reader) *logLineReader {
+	return &logLineReader{reader: bufio.NewReaderSize(r, 64*1024)}
+}
+
+func (lr *logLineReader) Scan() bool {
+	var line []byte
+	read, tooLong := 0, false
+	for {
+		chunk, err := lr.reader.ReadSlice('\n')
+		read += len(chunk)
+		if !tooLong {
+			if len(line)+len(chunk) > maxLogLineLength+2 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			lr.err = err
+			return false
+		}
+		if err == io.EOF && read == 0 {
+			return false
+		}
+		break
+	}
+	if tooLong {
+		lr.skipped++
+		lr.line = ""
+		return true
+	}
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	lr.line = string(line)
+	return true
+}
+
+func (lr *logLineReader) Text() string {
+	return lr.line
+}
+
+// Skipped return the number of lines skipped for their length
+func (lr *logLineReader) Skipped() int {
+	return lr.skipped
+}
+
+func (lr *logLineReader) Err() error {
+	return lr.err
+}
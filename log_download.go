@@ -1,19 +1,72 @@
 package main
 
 import (
+	"bufio"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
-func downloadLog(w http.ResponseWriter, r *http.Request) {
-	log.Println(r.URL)
-	if r.URL.Path != "/" {
-		http.Error(w, "Not found", http.StatusNotFound)
-		return
+// downloadLogHandler serve the log file the node is currently writing
+func downloadLogHandler(lsrv *logTailService, audit *auditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println(loggedURL(r))
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		node := r.URL.Query().Get("node")
+		identity := requestIdentity(r)
+		if !identity.canReadNode(node) || !identity.hasPermission(permDownload) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		tailer, ok := lsrv.getTailer(node)
+		if !ok {
+			http.Error(w, "Chain not exist", http.StatusNotFound)
+			return
+		}
+		file := tailer.currentFile()
+		fileHandle, err := os.Open(filepath.Join(tailer.logDir, file.Name()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer fileHandle.Close()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename="+file.Name())
+		written, err := writeRedactedLog(w, fileHandle, lsrv.currentRedactor())
+		if err != nil {
+			log.Printf("download of %v failed: %v\n", node, err)
+		}
+		audit.record(r, auditDownload, node, "file "+file.Name(), written)
 	}
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+}
+
+// writeRedactedLog copy a log line by line through the redactor, lines can be of any length, it return the
+// number of bytes written
+func writeRedactedLog(w io.Writer, r io.Reader, rd *redactor) (int64, error) {
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+	written := int64(0)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			n, werr := writer.WriteString(rd.redact(line))
+			written += int64(n)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
 	}
-	// http.ServeFile(w, r, "home.html")
+	return written, writer.Flush()
 }
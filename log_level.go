@@ -0,0 +1,38 @@
+package main
+
+import "regexp"
+
+var logLevelRe = regexp.MustCompile(`\[(trc|dbg|inf|wrn|err|crt)\]`)
+
+type LevelCounts struct {
+	Trace    int
+	Debug    int
+	Info     int
+	Warn     int
+	Error    int
+	Critical int
+	Unknown  int
+}
+
+// add count a lowercased log line under its level token, lines without level are unknown
+func (lc *LevelCounts) add(line string) {
+	match := logLevelRe.FindStringSubmatch(line)
+	if len(match) != 2 {
+		lc.Unknown++
+		return
+	}
+	switch match[1] {
+	case "trc":
+		lc.Trace++
+	case "dbg":
+		lc.Debug++
+	case "inf":
+		lc.Info++
+	case "wrn":
+		lc.Warn++
+	case "err":
+		lc.Error++
+	case "crt":
+		lc.Critical++
+	}
+}
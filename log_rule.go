@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRuleWindow = 5 * time.Minute
+	maxRuleLineLength = 200
+)
+
+type patternRuleConfig struct {
+	Name      string
+	Pattern   string
+	Chains    []string
+	Nodes     []string
+	Threshold int
+	Window    string
+	Severity  string
+}
+
+// patternRule alert when a log line matching pattern appear threshold times within window on a selected node
+type patternRule struct {
+	name      string
+	re        *regexp.Regexp
+	chains    []string
+	nodes     []string
+	threshold int
+	window    time.Duration
+	severity  string
+}
+
+type ruleHits struct {
+	lck  sync.Mutex
+	hits map[string][]time.Time
+}
+
+func newPatternRule(cfg patternRuleConfig) (*patternRule, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("rule with pattern %v has no name", cfg.Pattern)
+	}
+	// lines are lowercased before being parsed so the rules are case insensitive
+	re, err := regexp.Compile("(?i)" + cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rule %v: %v", cfg.Name, err)
+	}
+	rule := &patternRule{
+		name:      cfg.Name,
+		re:        re,
+		chains:    cfg.Chains,
+		nodes:     cfg.Nodes,
+		threshold: cfg.Threshold,
+		window:    defaultRuleWindow,
+		severity:  cfg.Severity,
+	}
+	if cfg.Window != "" {
+		rule.window, err = time.ParseDuration(cfg.Window)
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %v", cfg.Name, err)
+		}
+	}
+	if rule.threshold <= 0 {
+		rule.threshold = 1
+	}
+	if rule.severity == "" {
+		rule.severity = SeverityWarning
+	}
+	return rule, nil
+}
+
+func newPatternRules(cfgs []patternRuleConfig) ([]*patternRule, error) {
+	var rules []*patternRule
+	for _, cfg := range cfgs {
+		rule, err := newPatternRule(cfg)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (rule *patternRule) selects(chain, node string) bool {
+	return matchAny(rule.chains, chain) && matchAny(rule.nodes, node)
+}
+
+func (rule *patternRule) condition() string {
+	return "rule:" + rule.name
+}
+
+// prune drop the hits older than the rule window and return how many are left
+func (rh *ruleHits) prune(rule *patternRule, now time.Time) int {
+	hits := rh.hits[rule.name]
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) > rule.window {
+		i++
+	}
+	rh.hits[rule.name] = hits[i:]
+	return len(hits) - i
+}
+
+// evalPatternRules is called for every live line, it fire the rules reaching their threshold
+func (l *logTail) evalPatternRules(line string) {
+	node := l.nodeKey()
+	now := time.Now()
+	for _, rule := range l.logService.currentRules() {
+		if !rule.selects(l.chain, node) || !rule.re.MatchString(line) {
+			continue
+		}
+		l.ruleHits.lck.Lock()
+		l.ruleHits.hits[rule.name] = append(l.ruleHits.hits[rule.name], now)
+		count := l.ruleHits.prune(rule, now)
+		l.ruleHits.lck.Unlock()
+		if count >= rule.threshold {
+			// the line is kept as is for the next rules
+			excerpt := truncateRunes(l.logService.currentRedactor().redact(line), maxRuleLineLength)
+			text := fmt.Sprintf("Node %v matched rule %v %v times in %v: %v", node, rule.name, count, rule.window, excerpt)
+			l.logService.alerts.fire(l.chain, node, rule.condition(), rule.severity, text)
+		}
+	}
+}
+
+// checkPatternRules resolve the rules alerts which hits fell below the threshold
+func (l *logTail) checkPatternRules() {
+	node := l.nodeKey()
+	now := time.Now()
+	for _, rule := range l.logService.currentRules() {
+		if !rule.selects(l.chain, node) {
+			continue
+		}
+		l.ruleHits.lck.Lock()
+		count := l.ruleHits.prune(rule, now)
+		l.ruleHits.lck.Unlock()
+		if count < rule.threshold {
+			l.logService.alerts.resolve(l.chain, node, rule.condition(), fmt.Sprintf("Node %v rule %v back to normal 🎉", node, rule.name))
+		}
+	}
+}
+
+// truncateRunes cut text to max runes, a multi-byte character is never split
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "..."
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// TestEvalPatternRulesKeepLine check that a rule firing doesn't alter the line the next rules match against
+func TestEvalPatternRulesKeepLine(t *testing.T) {
+	rules, err := newPatternRules([]patternRuleConfig{
+		{Name: "first", Pattern: "panic"},
+		{Name: "late", Pattern: "tail-marker"},
+		{Name: "secret", Pattern: "112t8r"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd, err := newRedactor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsrv, fired := newTestService()
+	lsrv.rules, lsrv.redactor = rules, rd
+	l := &logTail{chain: "beacon", logService: lsrv, ruleHits: ruleHits{hits: make(map[string][]time.Time)}}
+	key := "112t8r" + strings.Repeat("a", 90)
+	line := "panic é " + key + " " + strings.Repeat("é", 300) + " tail-marker"
+	l.evalPatternRules(line)
+	if len(*fired) != 3 {
+		t.Fatalf("%v rules fired, expect 3: %v", len(*fired), *fired)
+	}
+	for _, text := range *fired {
+		if strings.Contains(text, key) {
+			t.Errorf("alert not redacted: %v", text)
+		}
+		if !utf8.ValidString(text) {
+			t.Errorf("alert text is not valid utf8: %q", text)
+		}
+	}
+}
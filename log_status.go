@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 )
 
 type LogStatusReponse struct {
@@ -12,6 +13,14 @@ type LogStatusReponse struct {
 	IsSuspectDown   bool
 	ErrorsCount     int
 	LatestErrorLine string
+	LevelCounts     LevelCounts
+	Ingest          IngestStatus
+	// NewErrorTemplates are the error templates recently seen for the first time on the node or its chain
+	NewErrorTemplates []string `json:",omitempty"`
+	// DegradedReason is the error that stopped the tailer of the node while it is restarted
+	IsDegraded     bool
+	DegradedReason string    `json:",omitempty"`
+	DegradedSince  time.Time `json:",omitempty"`
 }
 
 type BlockProducingStatus struct {
@@ -31,11 +40,12 @@ func streamStatusWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	client := &LogStreamer{hub: hub, conn: conn, send: make(chan []byte, 256), id: HashH([]byte(r.RemoteAddr))}
-	client.hub.register <- client
+	client := &LogStreamer{hub: hub, conn: conn, send: make(chan []byte, 256), id: HashH([]byte(r.RemoteAddr)), filter: requestIdentity(r).statusFilter()}
+	client.hub.add(client)
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
+	streamersWG.Add(1)
 	go client.writePump()
 	// go client.readPump()
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -19,14 +20,16 @@ var (
 	space   = []byte{' '}
 )
 
+// upgrader accept the websockets of the pages of the service, main set CheckOrigin to also accept the
+// configured origins
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
 }
 
+// streamersWG track the running writePump so the shutdown can wait for the close frames to be sent
+var streamersWG sync.WaitGroup
+
 // LogStreamer is a middleman between the websocket connection and the hub.
 type LogStreamer struct {
 	hub *Hub
@@ -37,13 +40,20 @@ type LogStreamer struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// filter drop the broadcast messages the client is not allowed to see, nil let everything through.
+	filter func(message []byte) bool
+
+	// sent is the number of bytes written to the connection, onClose receive it once the connection is closed.
+	sent    int64
+	onClose func(sent int64)
 }
 
 // readPump pumps messages from the websocket connection to the hub.
 func (c *LogStreamer) readPump() {
 	defer func() {
 		if c.hub != nil {
-			c.hub.unregister <- c
+			c.hub.leave(c)
 		}
 		c.conn.Close()
 	}()
@@ -56,17 +66,21 @@ func (c *LogStreamer) readPump() {
 			break
 		}
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
-		c.hub.broadcast <- message
+		c.hub.send(message)
 	}
 }
 
 // writePump pumps messages from the hub to the websocket connection.
 func (c *LogStreamer) writePump() {
+	defer streamersWG.Done()
 	defer func() {
 		if c.hub != nil {
-			c.hub.unregister <- c
+			c.hub.leave(c)
 		}
 		c.conn.Close()
+		if c.onClose != nil {
+			c.onClose(c.sent)
+		}
 	}()
 	for {
 		select {
@@ -82,7 +96,8 @@ func (c *LogStreamer) writePump() {
 				log.Println(err)
 				return
 			}
-			w.Write(message)
+			n, _ := w.Write(message)
+			c.sent += int64(n)
 
 			if err := w.Close(); err != nil {
 				log.Println(err)
@@ -93,14 +108,15 @@ func (c *LogStreamer) writePump() {
 }
 
 // streamlogWs handles websocket requests from the peer.
-func streamlogWs(hub *Hub, w http.ResponseWriter, r *http.Request, preStreamLogs []string) {
+func streamlogWs(hub *Hub, w http.ResponseWriter, r *http.Request, preStreamLogs []string, onClose func(sent int64)) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 	sendBuffer := 256
-	client := &LogStreamer{hub: hub, conn: conn, send: make(chan []byte, sendBuffer), id: HashH([]byte(r.RemoteAddr))}
+	client := &LogStreamer{hub: hub, conn: conn, send: make(chan []byte, sendBuffer), id: HashH([]byte(r.RemoteAddr)), onClose: onClose}
+	streamersWG.Add(1)
 	go client.writePump()
 
 	if len(preStreamLogs) > 0 {
@@ -109,18 +125,19 @@ func streamlogWs(hub *Hub, w http.ResponseWriter, r *http.Request, preStreamLogs
 		}
 	}
 
-	client.hub.register <- client
+	client.hub.add(client)
 	// go client.readPump()
 }
 
-func streamOnceWs(w http.ResponseWriter, r *http.Request, streamLogs []string) {
+func streamOnceWs(w http.ResponseWriter, r *http.Request, streamLogs []string, onClose func(sent int64)) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 	sendBuffer := 256
-	client := &LogStreamer{hub: nil, conn: conn, send: make(chan []byte, sendBuffer), id: HashH([]byte(r.RemoteAddr))}
+	client := &LogStreamer{hub: nil, conn: conn, send: make(chan []byte, sendBuffer), id: HashH([]byte(r.RemoteAddr)), onClose: onClose}
+	streamersWG.Add(1)
 	go client.writePump()
 
 	if len(streamLogs) > 0 {
@@ -1,14 +1,12 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"regexp"
 	"sort"
@@ -23,11 +21,44 @@ import (
 type logTailService struct {
 	currentTailerLck sync.RWMutex
 	currentTailer    map[string]*logTail
+	blockHeightLck   sync.RWMutex
 	chainBlockHeight map[string]int
-	notiChan         chan string
-	notiArray        []string
+	notifier         *notifierRouter
+	notiChan         chan Notification
+	dispatchQueue    chan []Notification
+	notiLck          sync.Mutex
+	notiArray        []Notification
+	alerts           *alertManager
+	alertHistory     *alertStore
+	rules            []*patternRule
+	errorCatalog     *errorCatalog
+	errorBaseline    *errorBaseline
+	blockTiming      *blockTimingTracker
+	forkTracker      *forkTracker
+	crossLinks       *crossLinkTracker
+	replay           *replayConfig
+	chainMonitor     chainMonitor
+	redactor         *redactor
+	// stopping is closed on shutdown, tailersWG track the goroutines of the tailers still running. tailersLck
+	// make the registration in tailersWG and the stopped check one step so Shutdown doesn't wait while one is added
+	stopping   chan struct{}
+	tailersWG  sync.WaitGroup
+	tailersLck sync.Mutex
+	stopped    bool
+	lHub       *logHub
+	statusHub  *Hub
+	readiness  readiness
+	logDir     string
+	// configLck guard notifier, rules and redactor, a reload replace them
+	configLck sync.RWMutex
+	// nodeStops hold the channel closed to stop each node, nodes are added and removed by a reload
+	nodesLck  sync.Mutex
+	nodeStops map[string]chan struct{}
 }
 
+// logTail follow the log of a node, stateLck guard the state written by the tailer goroutine and read by the
+// handlers and the status loop: file, latestBlockProducingStatus, isSuspectDown*, errorsCount, latestErrorLine,
+// heightsRecord and levelCounts
 type logTail struct {
 	chain                      string
 	nodeNumber                 int
@@ -41,23 +72,41 @@ type logTail struct {
 	resetTailLog               chan struct{}
 	errorsCount                int
 	latestErrorLine            string
-	heightsRecordLck           sync.RWMutex
+	stateLck                   sync.RWMutex
 	heightsRecord              map[int]*heightRecord
-	internalBuf                []byte
 	logService                 *logTailService
-	lastAlertSend              time.Time
+	isLive                     bool
+	levelCounts                LevelCounts
+	ruleHits                   ruleHits
+	ingest                     ingestStats
+	proposerStats              proposerStats
+	latestVotedHash            string
+	scanned                    bool
+	tailOffset                 int64
+	lineCount                  int
+	health                     tailerHealth
+	stop                       <-chan struct{}
+	// scanFedLines is how far a failed scan fed the shared trackers, a retry doesn't feed these lines again
+	scanFedLines int
+	// replayed hold the lines already replayed, nil when the service isn't replaying
+	replayed *replayBuffer
 }
 
 type heightRecord struct {
-	round      int
-	start      int
-	end        int
-	startTime  string
-	errorCount int
+	round       int
+	start       int
+	end         int
+	startTime   string
+	startAt     time.Time
+	errorCount  int
+	levelCounts LevelCounts
+	proposer    string
+	blockHash   string
 }
 
-func openLatestLogForStream(logDir, chain string, nodeNumber int, fileList []os.FileInfo, lHub *Hub, statusHub *Hub) *logTail {
-	filePrefix, fileSuffix := getLogFileName(chain, nodeNumber)
+// openLatestLogForStream wait for the log file of the node, it return nil when stop is closed first
+func openLatestLogForStream(logDir, chain string, nodeNumber int, date string, fileList []os.FileInfo, lHub *Hub, statusHub *Hub, stop <-chan struct{}) *logTail {
+	filePrefix, fileSuffix := getLogFileNameOfDate(chain, nodeNumber, date)
 OPENLATEST:
 	logFile := getLogFileForFileList(filePrefix, fileSuffix, fileList)
 	if logFile != nil {
@@ -69,64 +118,138 @@ OPENLATEST:
 			statusHub:    statusHub,
 			file:         logFile,
 			resetTailLog: make(chan struct{}),
+			ruleHits:     ruleHits{hits: make(map[string][]time.Time)},
+			stop:         stop,
 		}
 		return newTailer
 	}
 	//the wanted logFile not exist yet so wait for it
 	log.Printf("log file of %v not found, retrying...\n", chain+strconv.Itoa(nodeNumber))
-	time.Sleep(10 * time.Minute)
-	fileList, err := ioutil.ReadDir(logDir)
+	select {
+	case <-time.After(10 * time.Minute):
+	case <-stop:
+		return nil
+	}
+	newFileList, err := ioutil.ReadDir(logDir)
 	if err != nil {
-		log.Fatal(err)
+		log.Println("read log dir:", err)
+		goto OPENLATEST
 	}
+	fileList = newFileList
 	goto OPENLATEST
 }
 
-func (lsrv *logTailService) Init(logDir string, lHub *logHub, statusHub *Hub) {
+// initTrackers prepare the state shared by the tailers of every node
+func (lsrv *logTailService) initTrackers() {
 	lsrv.currentTailer = make(map[string]*logTail)
 	lsrv.chainBlockHeight = make(map[string]int)
+	lsrv.errorCatalog = newErrorCatalog()
+	lsrv.blockTiming = newBlockTimingTracker()
+	lsrv.forkTracker = newForkTracker()
+	lsrv.crossLinks = newCrossLinkTracker()
+	lsrv.chainMonitor.stall = make(map[string]*chainStallState)
+	lsrv.stopping = make(chan struct{})
+	lsrv.nodeStops = make(map[string]chan struct{})
+}
+
+func (lsrv *logTailService) Init(logDir string, lHub *logHub, statusHub *Hub) {
+	lsrv.initTrackers()
 	files, err := ioutil.ReadDir(logDir)
 	if err != nil {
 		log.Fatal(err)
 	}
-	go lsrv.slackHook()
-	for i := 0; i <= NumberOfBeaconNode-1; i++ {
-		go func(node int) {
-			n := "beacon" + strconv.Itoa(node)
-			hub := newHub()
-			lHub.add(n, hub)
-			streamer := openLatestLogForStream(logDir, "beacon", node, files, hub, statusHub)
-			streamer.logService = lsrv
-			lsrv.addLogStreamer(n, streamer)
+	lsrv.logDir, lsrv.lHub, lsrv.statusHub = logDir, lHub, statusHub
+	lsrv.notiChan = make(chan Notification, notifyQueueSize)
+	lsrv.dispatchQueue = make(chan []Notification, dispatchQueueSize)
+	lsrv.alerts = newAlertManager(lsrv.notify, lsrv.alertHistory)
+	go lsrv.notiHook()
+	go lsrv.dispatchLoop()
+	// they write to the alert store and the baseline file, Shutdown wait for them before closing these
+	lsrv.goTailer(lsrv.watchRoundStall)
+	lsrv.goTailer(lsrv.watchNewErrors)
+	date := lsrv.logDate()
+	lsrv.expectInitialScans(files, date)
+	for _, node := range nodeList() {
+		lsrv.startNode(node.chain, node.number, date, files)
+	}
+}
+
+// logDate is the date of the log files followed, today or the replayed date
+func (lsrv *logTailService) logDate() string {
+	if lsrv.replay != nil {
+		return lsrv.replay.date
+	}
+	return time.Now().Format("2006-01-02")
+}
+
+// startNode create the hub of the node then tail its log once the file is found
+func (lsrv *logTailService) startNode(chain string, number int, date string, files []os.FileInfo) {
+	n := chain + strconv.Itoa(number)
+	stop := make(chan struct{})
+	lsrv.nodesLck.Lock()
+	lsrv.nodeStops[n] = stop
+	lsrv.nodesLck.Unlock()
+	hub := newHub()
+	lsrv.lHub.add(n, hub)
+	go func() {
+		streamer := openLatestLogForStream(lsrv.logDir, chain, number, date, files, hub, lsrv.statusHub, stop)
+		if streamer == nil {
+			return
+		}
+		streamer.logService = lsrv
+		if lsrv.replay != nil {
+			streamer.replayed = newReplayBuffer()
+		}
+		if lsrv.addLogStreamer(n, streamer) {
 			streamer.Run()
-		}(i)
-	}
-
-	for s := 0; s <= NumberOfShards-1; s++ {
-		for i := 0; i <= NumberOfNodePerShard-1; i++ {
-			go func(shard int, node int) {
-				shardChain := "shard" + strconv.Itoa(shard)
-				n := shardChain + strconv.Itoa(node)
-				hub := newHub()
-				lHub.add(n, hub)
-				streamer := openLatestLogForStream(logDir, shardChain, node, files, hub, statusHub)
-				streamer.logService = lsrv
-				lsrv.addLogStreamer(n, streamer)
-				streamer.Run()
-			}(s, i)
 		}
-	}
+	}()
 }
 
-func (lsrv *logTailService) addLogStreamer(node string, streamer *logTail) {
+// stopNode stop the tailer of a node removed from the config, its clients are disconnected and its alerts dismissed
+func (lsrv *logTailService) stopNode(node string) {
+	lsrv.nodesLck.Lock()
+	stop, ok := lsrv.nodeStops[node]
+	delete(lsrv.nodeStops, node)
+	lsrv.nodesLck.Unlock()
+	if !ok {
+		return
+	}
+	close(stop)
 	lsrv.currentTailerLck.Lock()
-	lsrv.currentTailer[node] = streamer
+	delete(lsrv.currentTailer, node)
 	lsrv.currentTailerLck.Unlock()
+	lsrv.lHub.remove(node)
+	lsrv.forgetInitialScan(node)
+	lsrv.alerts.dismissMatching(func(chain, alertNode, condition string) bool {
+		return alertNode == node
+	})
 }
 
-func (lsrv *logTailService) updateBlockHeight(chain string, height int) {
+// addLogStreamer register the tailer of node, it return false when the node was removed while its file was awaited
+func (lsrv *logTailService) addLogStreamer(node string, streamer *logTail) bool {
 	lsrv.currentTailerLck.Lock()
 	defer lsrv.currentTailerLck.Unlock()
+	select {
+	case <-streamer.stop:
+		return false
+	default:
+	}
+	lsrv.currentTailer[node] = streamer
+	return true
+}
+
+// getTailer return the tailer of node, it is missing until the log file of the node is found
+func (lsrv *logTailService) getTailer(node string) (*logTail, bool) {
+	lsrv.currentTailerLck.RLock()
+	defer lsrv.currentTailerLck.RUnlock()
+	tailer, ok := lsrv.currentTailer[node]
+	return tailer, ok
+}
+
+func (lsrv *logTailService) updateBlockHeight(chain string, height int) {
+	lsrv.blockHeightLck.Lock()
+	defer lsrv.blockHeightLck.Unlock()
 	if h, ok := lsrv.chainBlockHeight[chain]; ok {
 		if height > h {
 			lsrv.chainBlockHeight[chain] = height
@@ -138,38 +261,140 @@ func (lsrv *logTailService) updateBlockHeight(chain string, height int) {
 }
 
 func (lsrv *logTailService) getBlockHeight(chain string) int {
-	lsrv.currentTailerLck.RLock()
-	defer lsrv.currentTailerLck.RUnlock()
+	lsrv.blockHeightLck.RLock()
+	defer lsrv.blockHeightLck.RUnlock()
 	if h, ok := lsrv.chainBlockHeight[chain]; ok {
 		return h
 	}
 	return 0
 }
 
-func (lsrv *logTailService) slackHook() {
-	lsrv.notiChan = make(chan string)
+func (lsrv *logTailService) notiHook() {
 	t := time.NewTicker(30 * time.Second)
 	for {
 		select {
 		case noti := <-lsrv.notiChan:
-			lsrv.currentTailerLck.Lock()
+			lsrv.notiLck.Lock()
 			lsrv.notiArray = append(lsrv.notiArray, noti)
-			lsrv.currentTailerLck.Unlock()
+			lsrv.notiLck.Unlock()
 		case <-t.C:
-			lsrv.currentTailerLck.Lock()
-			if len(lsrv.notiArray) > 0 {
-				texts := strings.Join(lsrv.notiArray, "\n")
-				sendSlackNoti(texts)
-				lsrv.notiArray = []string{}
+			lsrv.notiLck.Lock()
+			notis := lsrv.notiArray
+			lsrv.notiArray = []Notification{}
+			lsrv.notiLck.Unlock()
+			if len(notis) == 0 {
+				continue
+			}
+			select {
+			case lsrv.dispatchQueue <- notis:
+			default:
+				log.Printf("notifiers too slow, dropped %v notifications\n", len(notis))
 			}
-			lsrv.currentTailerLck.Unlock()
 		}
 	}
 }
 
-func (l *logTail) readLogLine(line string, lineCount int) {
+// dispatchLoop send the batches of notiHook, the notifiers are called from here only so they never block
+// the tailers or the alerts
+func (lsrv *logTailService) dispatchLoop() {
+	for notis := range lsrv.dispatchQueue {
+		lsrv.currentNotifier().dispatch(notis)
+	}
+}
+
+// flushNotifications dispatch the notifications still waiting, it give up when ctx is done
+func (lsrv *logTailService) flushNotifications(ctx context.Context) error {
+	lsrv.notiLck.Lock()
+	notis := lsrv.notiArray
+	lsrv.notiArray = []Notification{}
+	lsrv.notiLck.Unlock()
+	for drained := false; !drained; {
+		select {
+		case batch := <-lsrv.dispatchQueue:
+			notis = append(notis, batch...)
+		case noti := <-lsrv.notiChan:
+			notis = append(notis, noti)
+		default:
+			drained = true
+		}
+	}
+	if len(notis) == 0 {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		lsrv.currentNotifier().dispatch(notis)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errShutdownTimeout
+	}
+}
+
+// notify queue a notification for the next notiHook tick, it never block, the notification is dropped when
+// the queue is full
+func (lsrv *logTailService) notify(chain, node, severity, text string) {
+	log.Println(text)
+	noti := Notification{
+		Chain:    chain,
+		Node:     node,
+		Severity: severity,
+		Text:     text,
+		Time:     time.Now(),
+	}
+	select {
+	case lsrv.notiChan <- noti:
+	default:
+		log.Println("notification queue full, dropped:", text)
+	}
+}
+
+func (l *logTail) nodeKey() string {
+	return l.chain + strconv.Itoa(l.nodeNumber)
+}
+
+// currentFile return the log file being tailed, it change every day
+func (l *logTail) currentFile() os.FileInfo {
+	l.stateLck.RLock()
+	defer l.stateLck.RUnlock()
+	return l.file
+}
+
+// readLogLine update the state of the tailer with a line, the calls into the shared trackers and the alerts
+// are made once stateLck is released so a slow subsystem never block the readers of the tailer state. They
+// are skipped when shared is false
+func (l *logTail) readLogLine(line string, lineCount int, shared bool) {
 	line = strings.ToLower(line)
+	l.stateLck.Lock()
+	effects := l.parseLogLine(line, lineCount)
+	l.stateLck.Unlock()
+	if !shared {
+		return
+	}
+	if l.isLive {
+		l.evalPatternRules(line)
+	}
+	for _, effect := range effects {
+		effect()
+	}
+}
+
+// parseLogLine is called with stateLck held, it return the updates of the other subsystems to run after
+func (l *logTail) parseLogLine(line string, lineCount int) (effects []func()) {
 	currentHeight := int(l.latestBlockProducingStatus.BlockHeight)
+	l.levelCounts.add(line)
+	if currentHeight != 0 {
+		l.heightsRecord[currentHeight].levelCounts.add(line)
+	}
+	if l.chain == "beacon" && currentHeight != 0 && isCrossLinkLine(line) {
+		if shardHeights := parseCrossLinks(line); len(shardHeights) > 0 {
+			height := currentHeight
+			effects = append(effects, func() { l.logService.crossLinks.record(height, shardHeights) })
+		}
+	}
 	if strings.Contains(line, "consensus log") {
 		var re1 = regexp.MustCompile(`(?m)(\w+) ts: (\d+), (\w+) block (\d+), round (\d+)`)
 		bftStatus := re1.FindAllStringSubmatch(line, -1)
@@ -177,6 +402,7 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 			timeslot, _ := strconv.Atoi(bftStatus[0][2])
 			height, _ := strconv.Atoi(bftStatus[0][4])
 			round, _ := strconv.Atoi(bftStatus[0][5])
+			lastTimeslot := l.latestBlockProducingStatus.Timeslot
 			l.latestBlockProducingStatus.Phase = strings.ToUpper(bftStatus[0][3])
 			l.latestBlockProducingStatus.BlockHeight = int64(height)
 			l.latestBlockProducingStatus.Round = round
@@ -185,9 +411,14 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 			l.latestBlockProducingStatus.IsVoteSent = false
 			l.latestBlockProducingStatus.VoteCount = 0
 
+			if currentHeight != height {
+				// the votes seen at the previous height don't tell the block committed at this one
+				l.latestVotedHash = ""
+			}
 			if currentHeight != height && currentHeight != 0 {
 				record := l.heightsRecord[currentHeight]
 				record.end = lineCount - 1
+				l.proposerStats.settle(currentHeight, lastTimeslot)
 			}
 			if currentHeight == height {
 				record := l.heightsRecord[currentHeight]
@@ -200,14 +431,31 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 					start:     lineCount - 1,
 					round:     round,
 					startTime: sline[1],
+					startAt:   logLineTime(line),
 				}
 				l.heightsRecord[currentHeight] = &record
-				l.logService.updateBlockHeight(l.chain, currentHeight)
+				startAt := record.startAt
+				effects = append(effects, func() {
+					l.logService.updateBlockHeight(l.chain, height)
+					l.logService.blockTiming.record(l.chain, height, startAt, timeslot)
+				})
+			}
+			if l.latestBlockProducingStatus.Phase == "PROPOSE" {
+				l.heightsRecord[currentHeight].proposer = l.nodeKey()
+				l.proposerStats.addProposal(height, timeslot, round)
 			}
 
 			return
 		}
 
+		if strings.Contains(line, "proposer") {
+			var re1 = regexp.MustCompile(`(?m)proposer:? (\w+)`)
+			proposer := re1.FindAllStringSubmatch(line, -1)
+			if len(proposer) == 1 && currentHeight != 0 {
+				l.heightsRecord[currentHeight].proposer = proposer[0][1]
+			}
+		}
+
 		if strings.Contains(line, "sending vote...") {
 			l.latestBlockProducingStatus.Phase = "VOTING"
 			l.latestBlockProducingStatus.IsBlockReceived = true
@@ -222,11 +470,27 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 			vote := re1.FindAllStringSubmatch(line, -1)
 			if len(vote) == 1 {
 				l.latestBlockProducingStatus.VoteCount, _ = strconv.Atoi(vote[0][2])
+				l.latestVotedHash = vote[0][3]
+				if currentHeight != 0 {
+					height, round, hash, live := currentHeight, l.latestBlockProducingStatus.Round, vote[0][3], l.isLive
+					effects = append(effects, func() { l.logService.recordVote(l.chain, l.nodeKey(), height, round, hash, live) })
+				}
 			}
 			return
 		}
 		if strings.Contains(line, "commit block") {
 			l.latestBlockProducingStatus.Phase = "COMMIT"
+			// the committed hash is the one printed on the line or else the block the node received votes for
+			var re1 = regexp.MustCompile(`(?m)commit block ([0-9a-f]{8,})`)
+			hash := l.latestVotedHash
+			if commit := re1.FindAllStringSubmatch(line, -1); len(commit) == 1 {
+				hash = commit[0][1]
+			}
+			if currentHeight != 0 && hash != "" {
+				l.heightsRecord[currentHeight].blockHash = hash
+				height, live := currentHeight, l.isLive
+				effects = append(effects, func() { l.logService.recordCommit(l.chain, l.nodeKey(), height, hash, live) })
+			}
 			return
 		}
 	}
@@ -235,6 +499,13 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 	if strings.Contains(line, "[err]") {
 		l.errorsCount++
 		l.latestErrorLine = line
+		node, seen, live := l.nodeKey(), logLineTime(line), l.isLive
+		effects = append(effects, func() {
+			tpl := l.logService.errorCatalog.add(l.chain, node, line, seen)
+			if events := l.logService.errorBaseline.observe(tpl, l.chain, node, seen, live); len(events) > 0 {
+				l.logService.reportNewErrors(events)
+			}
+		})
 		if l.latestBlockProducingStatus.BlockHeight != 0 {
 			record := l.heightsRecord[int(l.latestBlockProducingStatus.BlockHeight)]
 			record.errorCount += 1
@@ -245,56 +516,114 @@ func (l *logTail) readLogLine(line string, lineCount int) {
 		record := l.heightsRecord[currentHeight]
 		record.end = lineCount - 1
 	}
+	return
+}
+
+var logTimeLayouts = []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05"}
+
+// parseLogLineTime parse the time at the beginning of a log line
+func parseLogLineTime(line string) (time.Time, bool) {
+	sline := strings.SplitN(line, " ", 3)
+	if len(sline) >= 2 {
+		for _, layout := range logTimeLayouts {
+			if t, err := time.ParseInLocation(layout, sline[0]+" "+sline[1], time.Local); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
 
+// logLineTime parse the time at the beginning of a log line, it fallback to now when the line has no time
+func logLineTime(line string) time.Time {
+	if t, ok := parseLogLineTime(line); ok {
+		return t
+	}
+	return time.Now()
 }
 
-func (l *logTail) tailLog() {
-	t, err := tail.TailFile(l.logDir+"/"+l.file.Name(), tail.Config{
+// tailLog follow the log from tailOffset until the service stop, it return the error that stopped the tail
+func (l *logTail) tailLog() error {
+	t, err := tail.TailFile(l.logDir+"/"+l.currentFile().Name(), tail.Config{
 		Follow:   true,
-		Location: &tail.SeekInfo{0, io.SeekEnd},
+		Location: &tail.SeekInfo{Offset: l.tailOffset, Whence: io.SeekStart},
 	})
-
 	if err != nil {
-		log.Fatal(err)
-	}
-	lineCount := 0
-	if l.latestBlockProducingStatus.BlockHeight != 0 {
-		lineCount = l.heightsRecord[int(l.latestBlockProducingStatus.BlockHeight)].end
+		return err
 	}
+	defer func() {
+		t.Stop()
+		t.Cleanup()
+	}()
+	l.setHealthy()
+	l.isLive = true
 	for {
 		select {
 		case <-l.resetTailLog:
 			t.Stop()
+			t.Cleanup()
+			l.stateLck.Lock()
 			l.errorsCount = 0
+			l.levelCounts = LevelCounts{}
 			l.latestErrorLine = ""
-			t, err = tail.TailFile(l.logDir+"/"+l.file.Name(), tail.Config{
+			l.heightsRecord = make(map[int]*heightRecord)
+			l.latestBlockProducingStatus = BlockProducingStatus{}
+			l.isSuspectDownCount = 0
+			l.stateLck.Unlock()
+			l.tailOffset = 0
+			l.lineCount = 0
+			t, err = tail.TailFile(l.logDir+"/"+l.currentFile().Name(), tail.Config{
 				Follow:   true,
-				Location: &tail.SeekInfo{0, io.SeekStart},
+				Location: &tail.SeekInfo{Offset: 0, Whence: io.SeekStart},
 			})
 			if err != nil {
-				log.Fatal(err)
+				return err
 			}
-			lineCount = 0
-			l.heightsRecord = make(map[int]*heightRecord)
-			l.latestBlockProducingStatus = BlockProducingStatus{}
-			l.isSuspectDownCount = 0
 			log.Println("Reset tailler successful")
-		case line := <-t.Lines:
-			lineCount++
-			l.readLogLine(line.Text, lineCount)
-			l.isSuspectDownCount = 0
-			go func() {
-				l.logHub.broadcast <- []byte(line.Text)
-			}()
+		case line, ok := <-t.Lines:
+			if !ok {
+				return fmt.Errorf("tail stopped: %v", t.Err())
+			}
+			if line.Err != nil {
+				return line.Err
+			}
+			l.tailOffset += int64(len(line.Text)) + 1
+			l.lineCount++
+			l.processLine(line.Text, l.lineCount)
+		case <-l.logService.stopping:
+			return nil
+		case <-l.stop:
+			return nil
 		}
 
 	}
 }
 
-func (l *logTail) RetrieveLineFromEOF(lines int) []string {
-	fileHandle, err := os.OpenFile(l.logDir+"/"+l.file.Name(), os.O_RDONLY, 0666)
+// processLine handle a new line of the log, from the tail or from a replay
+func (l *logTail) processLine(text string, lineCount int) {
+	l.ingest.record(len(text) + 1)
+	l.readLogLine(text, lineCount, true)
+	l.stateLck.Lock()
+	l.isSuspectDownCount = 0
+	l.stateLck.Unlock()
+	go func() {
+		l.logHub.send([]byte(l.logService.currentRedactor().redact(text)))
+	}()
+}
+
+// RetrieveLatestLines return the latest lines of the log, the latest first, in replay mode these are the
+// latest lines replayed and not the end of the file
+func (l *logTail) RetrieveLatestLines(lines int) ([]string, error) {
+	if l.replayed != nil {
+		return l.logService.currentRedactor().redactLines(l.replayed.latest(lines)), nil
+	}
+	return l.RetrieveLineFromEOF(lines)
+}
+
+func (l *logTail) RetrieveLineFromEOF(lines int) ([]string, error) {
+	fileHandle, err := os.OpenFile(l.logDir+"/"+l.currentFile().Name(), os.O_RDONLY, 0666)
 	if err != nil {
-		log.Fatal("Cannot open file")
+		return nil, err
 	}
 	defer fileHandle.Close()
 	result := []string{}
@@ -322,59 +651,139 @@ func (l *logTail) RetrieveLineFromEOF(lines int) []string {
 			break
 		}
 	}
-	return result
+	return l.logService.currentRedactor().redactLines(result), nil
 }
 
 func (l *logTail) Run() {
+	l.stateLck.Lock()
 	l.heightsRecord = make(map[int]*heightRecord)
-	fileHandle, err := os.OpenFile(l.logDir+"/"+l.file.Name(), os.O_RDONLY, 0666)
+	l.stateLck.Unlock()
+	if l.logService.replay != nil {
+		l.logService.recordInitialScan(l.nodeKey(), 0)
+		l.logService.goTailer(l.replayLog)
+	} else {
+		go l.watchDay()
+		l.logService.goTailer(l.supervise)
+	}
+	l.logService.goTailer(l.suspectDown)
+	l.logService.goTailer(l.sendLatestConsensusStatus)
+}
+
+// goTailer run f in a goroutine tracked by tailersWG, nothing is run once the service is stopping
+func (lsrv *logTailService) goTailer(f func()) {
+	lsrv.tailersLck.Lock()
+	defer lsrv.tailersLck.Unlock()
+	if lsrv.stopped {
+		return
+	}
+	lsrv.tailersWG.Add(1)
+	go func() {
+		defer lsrv.tailersWG.Done()
+		f()
+	}()
+}
+
+// scanLog read the whole file to build the heights record, tailLog continue from where it stopped. A failed scan
+// is retried from the start, the state of the tailer is reset and the lines already fed to the shared trackers
+// are not fed again
+func (l *logTail) scanLog() error {
+	l.stateLck.Lock()
+	l.heightsRecord = make(map[int]*heightRecord)
+	l.latestBlockProducingStatus = BlockProducingStatus{}
+	l.errorsCount = 0
+	l.latestErrorLine = ""
+	l.levelCounts = LevelCounts{}
+	l.latestVotedHash = ""
+	file := l.file
+	l.stateLck.Unlock()
+	l.proposerStats.reset()
+	l.isLive = false
+	fileHandle, err := os.OpenFile(l.logDir+"/"+file.Name(), os.O_RDONLY, 0666)
 	if err != nil {
-		log.Fatal("Cannot open file")
+		return err
 	}
+	defer fileHandle.Close()
 	lineCount := 1
-	scanner := bufio.NewScanner(fileHandle)
-	l.internalBuf = make([]byte, 0, 64*1024)
-	scanner.Buffer(l.internalBuf, 1024*1024)
-	for scanner.Scan() {
-		line := scanner.Text()
+	reader := newLogLineReader(fileHandle)
+	for reader.Scan() {
+		line := reader.Text()
 		lineCount++
-		l.readLogLine(line, lineCount)
+		l.readLogLine(line, lineCount, lineCount > l.scanFedLines)
+		if lineCount > l.scanFedLines {
+			l.scanFedLines = lineCount
+		}
 	}
-	if err := scanner.Err(); err != nil {
-		log.Fatal("Something went wrong here!", lineCount, err)
-		panic(1)
+	if err := reader.Err(); err != nil {
+		return fmt.Errorf("line %v: %v", lineCount, err)
+	}
+	if skipped := reader.Skipped(); skipped > 0 {
+		log.Printf("%v lines longer than %v bytes skipped in %v\n", skipped, maxLogLineLength, file.Name())
+	}
+	// the reader stop at EOF so the offset is the end of what was read
+	l.tailOffset, err = fileHandle.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	l.stateLck.RLock()
+	defer l.stateLck.RUnlock()
+	l.lineCount = 0
+	if l.latestBlockProducingStatus.BlockHeight != 0 {
+		l.lineCount = l.heightsRecord[int(l.latestBlockProducingStatus.BlockHeight)].end
 	}
+	return nil
+}
 
-	go l.tailLog()
-	go l.suspectDown()
-	go l.sendLatestConsensusStatus()
-	go func() {
-	DAYWATCH:
-		nextDate, _ := time.Parse("2006-01-02", time.Now().AddDate(0, 0, 1).Format("2006-01-02"))
-		t := time.NewTimer(time.Until(nextDate.Add(10 * time.Second)))
-		<-t.C
-		log.Println("Resetting log tailler")
-		filePrefix, fileSuffix := getLogFileName(l.chain, l.nodeNumber)
-	GETLOGFILE:
-		fileList, err := ioutil.ReadDir(l.logDir)
-		if err != nil {
-			log.Fatal(err)
-		}
-		logFile := getLogFileForFileList(filePrefix, fileSuffix, fileList)
-		if logFile == nil {
-			time.Sleep(5 * time.Second)
-			goto GETLOGFILE
-		}
-		l.file = logFile
-		l.resetTailLog <- struct{}{}
-		goto DAYWATCH
-	}()
+// watchDay switch the tailer to the log file of the new day
+func (l *logTail) watchDay() {
+DAYWATCH:
+	nextDate, _ := time.Parse("2006-01-02", time.Now().AddDate(0, 0, 1).Format("2006-01-02"))
+	t := time.NewTimer(time.Until(nextDate.Add(10 * time.Second)))
+	select {
+	case <-t.C:
+	case <-l.logService.stopping:
+		return
+	case <-l.stop:
+		return
+	}
+	log.Println("Resetting log tailler")
+	filePrefix, fileSuffix := getLogFileName(l.chain, l.nodeNumber)
+GETLOGFILE:
+	fileList, err := ioutil.ReadDir(l.logDir)
+	if err != nil {
+		log.Println("read log dir:", err)
+		time.Sleep(5 * time.Second)
+		goto GETLOGFILE
+	}
+	logFile := getLogFileForFileList(filePrefix, fileSuffix, fileList)
+	if logFile == nil {
+		time.Sleep(5 * time.Second)
+		goto GETLOGFILE
+	}
+	l.stateLck.Lock()
+	l.file = logFile
+	l.stateLck.Unlock()
+	select {
+	case l.resetTailLog <- struct{}{}:
+	case <-l.logService.stopping:
+		return
+	case <-l.stop:
+		return
+	}
+	goto DAYWATCH
 }
 
 func (l *logTail) sendLatestConsensusStatus() {
 	t := time.NewTicker(3 * time.Second)
+	defer t.Stop()
 	for {
-		<-t.C
+		select {
+		case <-t.C:
+		case <-l.logService.stopping:
+			return
+		case <-l.stop:
+			return
+		}
+		l.stateLck.RLock()
 		status := LogStatusReponse{
 			Node:            l.nodeNumber,
 			Chain:           l.chain,
@@ -382,81 +791,114 @@ func (l *logTail) sendLatestConsensusStatus() {
 			IsSuspectDown:   l.isSuspectDown,
 			ErrorsCount:     l.errorsCount,
 			LatestErrorLine: l.latestErrorLine,
+			LevelCounts:     l.levelCounts,
 		}
-		if chainHeight := l.logService.getBlockHeight(l.chain); int(l.latestBlockProducingStatus.BlockHeight) <= chainHeight-5 && l.latestBlockProducingStatus.BlockHeight != 0 {
+		isSuspectDown, isSuspectDownCount := l.isSuspectDown, l.isSuspectDownCount
+		l.stateLck.RUnlock()
+		status.LatestErrorLine = l.logService.currentRedactor().redact(status.LatestErrorLine)
+		producing := status.ProducingStatus
+		node := l.nodeKey()
+		l.ingest.sample()
+		l.checkIngestAnomaly(node)
+		status.Ingest = l.ingest.status()
+		status.DegradedReason, status.DegradedSince = l.degraded()
+		status.IsDegraded = status.DegradedReason != ""
+		status.NewErrorTemplates = l.logService.currentRedactor().redactLines(l.logService.errorBaseline.recentTemplates(l.chain, node))
+		alerts := l.logService.alerts
+		if chainHeight := l.logService.getBlockHeight(l.chain); int(producing.BlockHeight) <= chainHeight-5 && producing.BlockHeight != 0 {
 			status.IsSuspectDown = true
-			if time.Now().Sub(l.lastAlertSend) > time.Hour {
-				node := l.chain + strconv.Itoa(l.nodeNumber)
-				line := fmt.Sprintf("Node %v block height is behind %v 😱", node, chainHeight-int(l.latestBlockProducingStatus.BlockHeight))
-				log.Println(line)
-				l.logService.notiChan <- line
-				l.lastAlertSend = time.Now()
-			}
+			line := fmt.Sprintf("Node %v block height is behind %v 😱", node, chainHeight-int(producing.BlockHeight))
+			alerts.fire(l.chain, node, alertNodeBehind, SeverityWarning, line)
+		} else {
+			alerts.resolve(l.chain, node, alertNodeBehind, fmt.Sprintf("Node %v caught up at height %v 🎉", node, producing.BlockHeight))
 		}
 
-		if l.isSuspectDown && l.isSuspectDownCount > 10 && time.Now().Sub(l.lastAlertSend) > time.Hour {
-			node := l.chain + strconv.Itoa(l.nodeNumber)
+		if isSuspectDown && isSuspectDownCount > 10 {
 			line := fmt.Sprintf("Node %v stopped logging 😱", node)
-			log.Println(line)
-			l.logService.notiChan <- line
-			l.lastAlertSend = time.Now()
+			alerts.fire(l.chain, node, alertStoppedLogging, SeverityCritical, line)
+		} else if !isSuspectDown {
+			alerts.resolve(l.chain, node, alertStoppedLogging, fmt.Sprintf("Node %v resumed logging 🎉", node))
 		}
+		l.checkPatternRules()
 		statusBytes, _ := json.Marshal(status)
-		l.statusHub.broadcast <- statusBytes
+		l.statusHub.send(statusBytes)
 	}
 }
 
 func (l *logTail) suspectDown() {
 	t := time.NewTicker(30 * time.Second)
+	defer t.Stop()
 	for {
-		<-t.C
+		select {
+		case <-t.C:
+		case <-l.logService.stopping:
+			return
+		case <-l.stop:
+			return
+		}
+		l.stateLck.Lock()
 		l.isSuspectDownCount++
 		if l.isSuspectDownCount >= 10 {
 			l.isSuspectDown = true
 		} else {
 			l.isSuspectDown = false
 		}
+		l.stateLck.Unlock()
 	}
 }
 
-func (l *logTail) GetLogOfHeight(height int) []string {
+func (l *logTail) GetLogOfHeight(height int) ([]string, error) {
 	var result []string
-	blkHeight, ok := l.heightsRecord[height]
+	l.stateLck.RLock()
+	record, ok := l.heightsRecord[height]
+	var blkHeight heightRecord
+	if ok {
+		blkHeight = *record
+	}
+	file := l.file
+	l.stateLck.RUnlock()
 	if !ok {
-		return nil
+		return nil, nil
 	}
 
-	fileHandle, err := os.OpenFile(l.logDir+"/"+l.file.Name(), os.O_RDONLY, 0666)
+	fileHandle, err := os.OpenFile(l.logDir+"/"+file.Name(), os.O_RDONLY, 0666)
 	if err != nil {
-		log.Fatal("Cannot open file")
+		return nil, err
 	}
+	defer fileHandle.Close()
 
 	fileHandle.Seek(0, io.SeekStart)
-	scanner := bufio.NewScanner(fileHandle)
+	reader := newLogLineReader(fileHandle)
 	currentLine := 1
-	scanner.Buffer(l.internalBuf, 1024*1024)
-	for scanner.Scan() {
+	for reader.Scan() {
 		if currentLine >= blkHeight.start {
-			result = append(result, scanner.Text())
+			result = append(result, reader.Text())
 		}
 		currentLine++
 		if (blkHeight.end != 0) && ((currentLine - blkHeight.start) == (blkHeight.end - blkHeight.start)) {
 			break
 		}
 	}
-	return result
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+	return l.logService.currentRedactor().redactLines(result), nil
 }
 
 type BlockInfo struct {
-	Round      int
-	Height     int
-	ErrorCount int
-	StartTime  string
+	Round       int
+	Height      int
+	ErrorCount  int
+	StartTime   string
+	StartAt     time.Time
+	LevelCounts LevelCounts
+	Proposer    string
+	BlockHash   string
 }
 
 func (l *logTail) GetHeightsRecord() []BlockInfo {
 	var result []BlockInfo
-	l.heightsRecordLck.RLock()
+	l.stateLck.RLock()
 	var sortRecord []int
 	for h := range l.heightsRecord {
 		sortRecord = append(sortRecord, h)
@@ -464,14 +906,18 @@ func (l *logTail) GetHeightsRecord() []BlockInfo {
 	sort.Ints(sortRecord)
 
 	for _, height := range sortRecord {
-		result = append(result, BlockInfo{Round: l.heightsRecord[height].round, Height: height, ErrorCount: l.heightsRecord[height].errorCount, StartTime: l.heightsRecord[height].startTime})
+		record := l.heightsRecord[height]
+		result = append(result, BlockInfo{Round: record.round, Height: height, ErrorCount: record.errorCount, StartTime: record.startTime, StartAt: record.startAt, LevelCounts: record.levelCounts, Proposer: record.proposer, BlockHash: record.blockHash})
 	}
-	l.heightsRecordLck.RUnlock()
+	l.stateLck.RUnlock()
 	return result
 }
 
 func getLogFileName(chain string, nodeNumber int) (string, string) {
-	date := time.Now().Format("2006-01-02")
+	return getLogFileNameOfDate(chain, nodeNumber, time.Now().Format("2006-01-02"))
+}
+
+func getLogFileNameOfDate(chain string, nodeNumber int, date string) (string, string) {
 	filePrefix := chain + strconv.Itoa(nodeNumber)
 	if chain == "beacon" {
 		filePrefix += "_fullnode"
@@ -500,25 +946,3 @@ func getLogFileForFileList(filePrefix, fileSuffix string, fileList []os.FileInfo
 	}
 	return logFile
 }
-
-func sendSlackNoti(text string) {
-	content := struct {
-		Text string `json:"text"`
-	}{
-		Text: text,
-	}
-	contentBytes, err := json.Marshal(content)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	httpClient := http.DefaultClient
-	resp, err := httpClient.Post(os.Getenv("SLACKHOOK"), "application/json", bytes.NewReader(contentBytes))
-	if resp.Status != "200" || err != nil {
-		log.Println(err)
-		body, _ := ioutil.ReadAll(resp.Body)
-		log.Println(string(body))
-	}
-	defer resp.Body.Close()
-	return
-}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// withTopology set the topology for the time of the test
+func withTopology(t *testing.T, topology nodesConfig) {
+	previous := currentTopology()
+	setTopology(topology)
+	t.Cleanup(func() { setTopology(previous) })
+}
+
+// newTestService return a service with its trackers and the texts of the alerts it fire, nothing is started
+func newTestService() (*logTailService, *[]string) {
+	var fired []string
+	lsrv := &logTailService{errorBaseline: newErrorBaseline("", defaultNewErrorBaseline)}
+	lsrv.initTrackers()
+	lsrv.alerts = newAlertManager(func(chain, node, severity, text string) {
+		fired = append(fired, text)
+	}, nil)
+	return lsrv, &fired
+}
+
+// startTestService run the service on a log directory with the nodes of topology
+func startTestService(t *testing.T, dir string, topology nodesConfig) (*logTailService, *logHub, func()) {
+	t.Helper()
+	withTopology(t, topology)
+	lsrv := &logTailService{errorBaseline: newErrorBaseline(filepath.Join(dir, "baseline.json"), defaultNewErrorBaseline)}
+	lHub := &logHub{hubs: make(map[string]*Hub)}
+	statusHub := newHub()
+	go statusHub.run()
+	lsrv.Init(dir, lHub, statusHub)
+	return lsrv, lHub, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		lHub.closeAll()
+		statusHub.close()
+		if err := lsrv.Shutdown(ctx); err != nil {
+			t.Error("shutdown:", err)
+		}
+	}
+}
+
+func consensusLines(height int) []string {
+	ts := fmt.Sprintf("2026-10-19 10:%02d:%02d.000", height/60%60, height%60)
+	return []string{
+		fmt.Sprintf("%v blsbft.go:1 [INF] Consensus log beacon ts: %v, listen block %v, round 1", ts, 100+height, height),
+		ts + " blsbft.go:1 [INF] Consensus log sending vote...",
+		fmt.Sprintf("%v blsbft.go:1 [INF] Consensus log beacon receive vote (3) for block %064x from validator 2 key", ts, height),
+		ts + " blsbft.go:1 [INF] Consensus log commit block",
+		fmt.Sprintf("%v blsbft.go:1 [ERR] request %v failed", ts, height),
+	}
+}
+
+// TestConcurrentStreamingAndQueries append to a log followed by the service while websocket clients stream it
+// and the handlers query the tailer, run it with -race
+func TestConcurrentStreamingAndQueries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	logPath := filepath.Join(dir, "beacon0_fullnode_1.2.3.4_"+time.Now().Format("2006-01-02")+".log")
+	if err := ioutil.WriteFile(logPath, []byte(strings.Join(consensusLines(1), "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lsrv, lHub, stop := startTestService(t, dir, nodesConfig{BeaconNodes: 1})
+	defer stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	tailer, ok := lsrv.getTailer("beacon0")
+	for !ok || !lsrv.readyStatus().Ready {
+		if time.Now().After(deadline) {
+			t.Fatal("initial scan not done")
+		}
+		time.Sleep(10 * time.Millisecond)
+		tailer, ok = lsrv.getTailer("beacon0")
+	}
+	hub, _ := lHub.get("beacon0")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamlogWs(hub, w, r, nil, nil)
+	}))
+	defer server.Close()
+
+	const heights = 100
+	var wg sync.WaitGroup
+	received := make(chan int, 4)
+	for c := 0; c < 4; c++ {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count := 0
+			for {
+				conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+				if _, _, err := conn.ReadMessage(); err != nil {
+					received <- count
+					return
+				}
+				count++
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+		for h := 2; h <= heights; h++ {
+			file.WriteString(strings.Join(consensusLines(h), "\n") + "\n")
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+	handler := chainStatusHandler(lsrv)
+	metrics := metricsHandler(lsrv)
+	for q := 0; q < 4; q++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				tailer.GetHeightsRecord()
+				if _, err := tailer.GetLogOfHeight(1); err != nil {
+					t.Error(err)
+				}
+				if _, err := tailer.RetrieveLineFromEOF(10); err != nil {
+					t.Error(err)
+				}
+				handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/chainstatus", nil))
+				metrics(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+				lsrv.getBlockHeight("beacon")
+			}
+		}()
+	}
+
+	<-done
+	deadline = time.Now().Add(5 * time.Second)
+	for lsrv.getBlockHeight("beacon") != heights {
+		if time.Now().After(deadline) {
+			t.Fatalf("block height %v, expect %v", lsrv.getBlockHeight("beacon"), heights)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	close(received)
+	for count := range received {
+		if count == 0 {
+			t.Error("a websocket client received no line")
+		}
+	}
+	if records := tailer.GetHeightsRecord(); len(records) != heights {
+		t.Errorf("%v heights recorded, expect %v", len(records), heights)
+	}
+	var buf bytes.Buffer
+	lsrv.writeServiceMetrics(&buf, nil)
+	if !strings.Contains(buf.String(), `logviewer_websocket_clients{hub="beacon0"}`) {
+		t.Error("websocket clients metric missing")
+	}
+}
+
+// TestScanLogRetry scan a log holding a line longer than the reader limit twice, as the supervisor retry a
+// failed scan, the counts must be the ones of a single scan
+func TestScanLogRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lines := append(consensusLines(1), strings.Repeat("x", maxLogLineLength+10))
+	lines = append(lines, consensusLines(2)...)
+	name := "beacon0_fullnode_1.2.3.4_2026-10-19.log"
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsrv, _ := newTestService()
+	l := openLatestLogForStream(dir, "beacon", 0, "2026-10-19", files, newHub(), newHub(), nil)
+	l.logService = lsrv
+	for i := 0; i < 2; i++ {
+		if err := l.scanLog(); err != nil {
+			t.Fatalf("scan %v: %v", i, err)
+		}
+	}
+	if l.errorsCount != 2 {
+		t.Errorf("errorsCount %v, expect 2", l.errorsCount)
+	}
+	if len(l.heightsRecord) != 2 {
+		t.Errorf("%v heights recorded, expect 2", len(l.heightsRecord))
+	}
+	count := 0
+	for _, tpl := range lsrv.errorCatalog.topErrors(func(chain, node string) bool { return true }, 0) {
+		count += tpl.Count
+	}
+	if count != 2 {
+		t.Errorf("error catalog counted %v errors, expect 2", count)
+	}
+	heightLog, err := l.GetLogOfHeight(2)
+	if err != nil || len(heightLog) == 0 {
+		t.Errorf("log of height 2: %v lines, %v", len(heightLog), err)
+	}
+}
+
+// TestNoTailerAfterShutdown check the goroutines of the tailers are waited by Shutdown and none start afterward
+func TestNoTailerAfterShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lsrv, _, stop := startTestService(t, dir, nodesConfig{BeaconNodes: 1})
+	finished := make(chan struct{})
+	lsrv.goTailer(func() {
+		<-lsrv.stopping
+		time.Sleep(50 * time.Millisecond)
+		close(finished)
+	})
+	stop()
+	select {
+	case <-finished:
+	default:
+		t.Error("Shutdown returned before a tailer goroutine")
+	}
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	if strings.Contains(stacks, "watchRoundStall") || strings.Contains(stacks, "watchNewErrors") {
+		t.Error("watchers still running after Shutdown")
+	}
+	lsrv.goTailer(func() {
+		t.Error("tailer goroutine started after Shutdown")
+	})
+	time.Sleep(50 * time.Millisecond)
+}
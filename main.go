@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"sync"
+	"syscall"
 )
 
 func serveHome(w http.ResponseWriter, r *http.Request) {
-	log.Println(r.URL)
+	log.Println(loggedURL(r))
 	if r.URL.Path != "/" {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
@@ -34,39 +41,176 @@ func (lhub *logHub) add(key string, hub *Hub) {
 	lhub.hubsLck.Unlock()
 }
 
+func (lhub *logHub) get(key string) (*Hub, bool) {
+	lhub.hubsLck.RLock()
+	defer lhub.hubsLck.RUnlock()
+	hub, ok := lhub.hubs[key]
+	return hub, ok
+}
+
+// remove close the hub of a node removed from the config
+func (lhub *logHub) remove(key string) {
+	lhub.hubsLck.Lock()
+	hub, ok := lhub.hubs[key]
+	delete(lhub.hubs, key)
+	lhub.hubsLck.Unlock()
+	if ok {
+		hub.close()
+	}
+}
+
+func (lhub *logHub) closeAll() {
+	lhub.hubsLck.RLock()
+	defer lhub.hubsLck.RUnlock()
+	for _, hub := range lhub.hubs {
+		hub.close()
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		runReport(os.Args[2:])
+		return
+	}
+
 	var addr = flag.String("addr", ":8084", "http service address")
 	var logdir = flag.String("dir", "./", "logs directory")
+	var configFile = flag.String("config", "", "service config file (notifiers, routes, rules, nodes, auth), reloaded on SIGHUP")
+	var replayDate = flag.String("replay", "", "replay the logs of a past date (YYYY-MM-DD) as if they were live")
+	var replaySpeed = flag.Float64("speed", 1, "replay speed multiplier")
+	var replayNotify = flag.Bool("replaynotify", false, "send the alerts of a replay to the configured notifiers")
+	var dataDir = flag.String("datadir", "./viewerdata", "directory to persist service data")
+	var tlsCert = flag.String("tlscert", "", "TLS certificate file, serve https when set with tlskey")
+	var tlsKey = flag.String("tlskey", "", "TLS private key file")
+	var tlsClientCA = flag.String("tlsclientca", "", "CA file to verify client certificates, their common name is used as identity")
+	var httpRedirectAddr = flag.String("httpredirect", "", "http address redirecting to https, e.g. :80")
 
 	flag.Parse()
 
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal("loadConfig: ", err)
+	}
+	nodes, err := config.topology()
+	if err != nil {
+		log.Fatal("Nodes: ", err)
+	}
+	setTopology(nodes)
+	var replay *replayConfig
+	if *replayDate != "" {
+		if *replaySpeed <= 0 {
+			log.Fatal("speed must be positive")
+		}
+		replay = &replayConfig{date: *replayDate, speed: *replaySpeed, notify: *replayNotify}
+	}
+	notifier, err := replay.notifierRouter(config)
+	if err != nil {
+		log.Fatal("newNotifierRouter: ", err)
+	}
+
 	lHub := logHub{
 		hubs: make(map[string]*Hub),
 	}
 
+	auth, err := newAuthService(config.Auth)
+	if err != nil {
+		log.Fatal("newAuthService: ", err)
+	}
+	upgrader.CheckOrigin = auth.originAllowed
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("tlscert and tlskey must be set together")
+	}
+	if *tlsCert == "" && (*tlsClientCA != "" || *httpRedirectAddr != "") {
+		log.Fatal("tlsclientca and httpredirect require tlscert and tlskey")
+	}
+	if *tlsClientCA != "" {
+		auth.addAuthenticator(&certAuthenticator{})
+	}
+	if !auth.enabled() {
+		log.Println("no authentication configured, the logs are readable by anyone")
+	}
+	rules, err := newPatternRules(config.Rules)
+	if err != nil {
+		log.Fatal("newPatternRules: ", err)
+	}
+	redactor, err := newRedactor(config.Redactions)
+	if err != nil {
+		log.Fatal("newRedactor: ", err)
+	}
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		log.Fatal("MkdirAll: ", err)
+	}
+	audit, err := openAuditLog(filepath.Join(*dataDir, "audit.jsonl"))
+	if err != nil {
+		log.Fatal("openAuditLog: ", err)
+	}
+	// a replay keep its alerts and error baseline apart, they would mix a past day into the history of the service
+	stateDir := *dataDir
+	if replay != nil {
+		if stateDir, err = ioutil.TempDir("", "logviewer-replay"); err != nil {
+			log.Fatal("TempDir: ", err)
+		}
+		defer os.RemoveAll(stateDir)
+	}
+	alertHistory, err := openAlertStore(filepath.Join(stateDir, "alerts.jsonl"))
+	if err != nil {
+		log.Fatal("openAlertStore: ", err)
+	}
+
+	baselineWindow, err := config.newErrorBaseline()
+	if err != nil {
+		log.Fatal("NewErrorBaseline: ", err)
+	}
+	errorBaseline, err := loadErrorBaseline(filepath.Join(stateDir, "error_baseline.json"), baselineWindow)
+	if err != nil {
+		log.Fatal("loadErrorBaseline: ", err)
+	}
+
 	statusHub := newHub()
 	go statusHub.run()
-	go watchDiskUsage(*logdir)
-	logService := logTailService{}
+	logService := logTailService{notifier: notifier, alertHistory: alertHistory, rules: rules, errorBaseline: errorBaseline, redactor: redactor, replay: replay}
+	go watchDiskUsage(*logdir, &logService)
 	logService.Init(*logdir, &lHub, statusHub)
+	reloader := newConfigReloader(*configFile, config, &logService)
 
 	fileServer := http.FileServer(http.Dir("./web"))
 	http.Handle("/", fileServer)
 	staticHandler := http.StripPrefix("/logviewer", http.FileServer(http.Dir("./web")))
 	http.HandleFunc("/getdiskleft", diskLeftHandler)
 	http.Handle("/logviewer", staticHandler)
-	http.HandleFunc("/downloadlog", downloadLog)
+	http.HandleFunc("/downloadlog", downloadLogHandler(&logService, audit))
 	http.HandleFunc("/streamlog", func(w http.ResponseWriter, r *http.Request) {
 		node := r.URL.Query().Get("node")
+		if !requestIdentity(r).canReadNode(node) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 
-		if nodeLogHub, ok := lHub.hubs[node]; ok {
+		if nodeLogHub, ok := lHub.get(node); ok {
+			tailer, ok := logService.getTailer(node)
+			if !ok {
+				http.Error(w, "Node log not found yet", http.StatusServiceUnavailable)
+				return
+			}
 			//retrieve lines from EOF
 			lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
 			preStreamLog := []string{}
 			if lines > 0 {
-				preStreamLog = logService.currentTailer[node].RetrieveLineFromEOF(lines)
+				var err error
+				preStreamLog, err = tailer.RetrieveLatestLines(lines)
+				if err != nil {
+					http.Error(w, "Cannot read node log: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
 			}
-			streamlogWs(nodeLogHub, w, r, preStreamLog)
+			rng := "live"
+			if lines > 0 {
+				rng = fmt.Sprintf("last %v lines and live", lines)
+			}
+			audit.record(r, auditStream, node, rng, 0)
+			streamlogWs(nodeLogHub, w, r, preStreamLog, func(sent int64) {
+				audit.record(r, auditStreamEnd, node, rng, sent)
+			})
 		} else {
 			http.Error(w, "Chain not exist", 404)
 		}
@@ -74,8 +218,17 @@ func main() {
 	http.HandleFunc("/getnodesheight", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		node := r.URL.Query().Get("node")
-		if _, ok := lHub.hubs[node]; ok {
-			heights := logService.currentTailer[node].GetHeightsRecord()
+		if !requestIdentity(r).canReadNode(node) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if _, ok := lHub.get(node); ok {
+			tailer, ok := logService.getTailer(node)
+			if !ok {
+				http.Error(w, "Node log not found yet", http.StatusServiceUnavailable)
+				return
+			}
+			heights := tailer.GetHeightsRecord()
 			heightsByte, _ := json.Marshal(heights)
 			w.Write(heightsByte)
 			return
@@ -86,22 +239,115 @@ func main() {
 	})
 	http.HandleFunc("/getheightlog", func(w http.ResponseWriter, r *http.Request) {
 		node := r.URL.Query().Get("node")
-		if _, ok := lHub.hubs[node]; ok {
+		if !requestIdentity(r).canReadNode(node) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if _, ok := lHub.get(node); ok {
+			tailer, ok := logService.getTailer(node)
+			if !ok {
+				http.Error(w, "Node log not found yet", http.StatusServiceUnavailable)
+				return
+			}
 			height, _ := strconv.Atoi(r.URL.Query().Get("height"))
 			heightlogs := []string{}
 			if height > 0 {
-				heightlogs = logService.currentTailer[node].GetLogOfHeight(height)
+				var err error
+				heightlogs, err = tailer.GetLogOfHeight(height)
+				if err != nil {
+					http.Error(w, "Cannot read node log: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
 			}
-			streamOnceWs(w, r, heightlogs)
+			streamOnceWs(w, r, heightlogs, func(sent int64) {
+				audit.record(r, auditHeightLog, node, "height "+strconv.Itoa(height), sent)
+			})
 		} else {
 			http.Error(w, "Chain not exist", 404)
 		}
 	})
+	http.HandleFunc("/api/chainstatus", chainStatusHandler(&logService))
+	http.HandleFunc("/api/audit", auditHandler(audit))
+	http.HandleFunc("/api/reload", reloadHandler(reloader))
+	http.HandleFunc("/api/alerts", alertHistoryHandler(alertHistory))
+	http.HandleFunc("/api/alerts/open", openAlertsHandler(logService.alerts))
+	http.HandleFunc("/api/alerts/ack", ackAlertHandler(logService.alerts))
+	http.HandleFunc("/api/alerts/silence", silenceAlertHandler(logService.alerts))
+	http.HandleFunc("/api/toperrors", topErrorsHandler(&logService))
+	http.HandleFunc("/metrics", metricsHandler(&logService))
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/readyz", readyzHandler(&logService))
+	http.HandleFunc("/api/blocktiming", blockTimingHandler(logService.blockTiming))
+	http.HandleFunc("/api/proposers", proposerStatsHandler(&logService))
+	http.HandleFunc("/api/forks", forksHandler(logService.forkTracker))
+	http.HandleFunc("/api/crosslinks", crossLinksHandler(&logService))
 	http.HandleFunc("/logstatus", func(w http.ResponseWriter, r *http.Request) {
 		streamStatusWs(statusHub, w, r)
 	})
-	err := http.ListenAndServe(*addr, nil)
-	if err != nil {
+	server := &http.Server{Addr: *addr, Handler: auth.checkOrigin(auth.require(http.DefaultServeMux))}
+	var redirectServer *http.Server
+	if *tlsCert != "" {
+		reloader, err := newCertReloader(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal("newCertReloader: ", err)
+		}
+		server.TLSConfig, err = newTLSConfig(reloader, *tlsClientCA)
+		if err != nil {
+			log.Fatal("newTLSConfig: ", err)
+		}
+		if *httpRedirectAddr != "" {
+			redirectServer = &http.Server{Addr: *httpRedirectAddr, Handler: redirectToHTTPS(*addr)}
+			go func() {
+				if err := redirectServer.ListenAndServe(); err != http.ErrServerClosed {
+					log.Fatal("redirect ListenAndServe: ", err)
+				}
+			}()
+		}
+	}
+
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			if _, err := reloader.reload(); err != nil {
+				log.Println("config not reloaded:", err)
+			}
+		}
+	}()
+
+	stopped := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigs
+		log.Printf("received %v, shutting down\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		// Shutdown doesn't track the hijacked websocket connections, the hubs close them
+		if err := server.Shutdown(ctx); err != nil {
+			log.Println("server shutdown:", err)
+		}
+		if redirectServer != nil {
+			redirectServer.Shutdown(ctx)
+		}
+		lHub.closeAll()
+		statusHub.close()
+		if err := waitGroupContext(ctx, &streamersWG); err != nil {
+			log.Println("websockets not closed:", err)
+		}
+		logService.Shutdown(ctx)
+		audit.close()
+		close(stopped)
+	}()
+
+	if *tlsCert == "" {
+		err = server.ListenAndServe()
+	} else {
+		err = server.ListenAndServeTLS("", "")
+	}
+	if err != http.ErrServerClosed {
 		log.Fatal("ListenAndServe: ", err)
 	}
+	<-stopped
+	log.Println("shutdown complete")
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// metricsHandler expose the service metrics in the prometheus text format
+func metricsHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var buf bytes.Buffer
+		lsrv.writeIngestMetrics(&buf, requestIdentity(r))
+		lsrv.writeServiceMetrics(&buf, requestIdentity(r))
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	}
+}
+
+func (lsrv *logTailService) writeIngestMetrics(buf *bytes.Buffer, identity *Identity) {
+	lsrv.currentTailerLck.RLock()
+	var nodes []string
+	tailers := make(map[string]*logTail)
+	for node, tailer := range lsrv.currentTailer {
+		if !identity.canReadNode(node) {
+			continue
+		}
+		nodes = append(nodes, node)
+		tailers[node] = tailer
+	}
+	lsrv.currentTailerLck.RUnlock()
+	sort.Strings(nodes)
+
+	statuses := make(map[string]IngestStatus)
+	for _, node := range nodes {
+		statuses[node] = tailers[node].ingest.status()
+	}
+	writeNodeMetric := func(name, help, metricType string, value func(IngestStatus) string) {
+		fmt.Fprintf(buf, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, metricType)
+		for _, node := range nodes {
+			fmt.Fprintf(buf, "%v{chain=%q,node=%q} %v\n", name, tailers[node].chain, node, value(statuses[node]))
+		}
+	}
+	writeNodeMetric("logviewer_ingest_lines_per_second_short", "Lines read per second, short moving average", "gauge", func(s IngestStatus) string { return fmt.Sprint(s.LinesPerSecShort) })
+	writeNodeMetric("logviewer_ingest_lines_per_second_long", "Lines read per second, long moving average", "gauge", func(s IngestStatus) string { return fmt.Sprint(s.LinesPerSecLong) })
+	writeNodeMetric("logviewer_ingest_bytes_per_second_short", "Bytes read per second, short moving average", "gauge", func(s IngestStatus) string { return fmt.Sprint(s.BytesPerSecShort) })
+	writeNodeMetric("logviewer_ingest_bytes_per_second_long", "Bytes read per second, long moving average", "gauge", func(s IngestStatus) string { return fmt.Sprint(s.BytesPerSecLong) })
+	writeNodeMetric("logviewer_ingest_lines_total", "Lines read since start", "counter", func(s IngestStatus) string { return fmt.Sprint(s.TotalLines) })
+	writeNodeMetric("logviewer_ingest_bytes_total", "Bytes read since start", "counter", func(s IngestStatus) string { return fmt.Sprint(s.TotalBytes) })
+	writeNodeMetric("logviewer_seconds_since_last_line", "Seconds since the last line was read", "gauge", func(s IngestStatus) string { return fmt.Sprint(s.SinceLastLine) })
+}
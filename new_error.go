@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	alertNewError = "new-error"
+
+	defaultNewErrorBaseline = 7 * 24 * time.Hour
+	// no template is reported as new before the baseline collected this long
+	newErrorWarmup       = time.Hour
+	newErrorRecentWindow = time.Hour
+	newErrorSaveInterval = 5 * time.Minute
+)
+
+// errorBaseline remember when every template was last seen on each node and chain, it outlive restarts
+type errorBaseline struct {
+	lck sync.Mutex
+	// saveLck serialize the writes of the file, they go through the same temporary file
+	saveLck   sync.Mutex
+	path      string
+	window    time.Duration
+	Started   time.Time
+	Templates map[string]*errorBaselineEntry
+	recent    []NewErrorEvent
+}
+
+type errorBaselineEntry struct {
+	Template string
+	Nodes    map[string]time.Time
+	Chains   map[string]time.Time
+}
+
+type NewErrorEvent struct {
+	Fingerprint Hash
+	Template    string
+	Chain       string
+	// Node is empty when the template is new for the whole chain
+	Node string
+	Time time.Time
+}
+
+func newErrorBaseline(path string, window time.Duration) *errorBaseline {
+	return &errorBaseline{
+		path:      path,
+		window:    window,
+		Started:   time.Now(),
+		Templates: make(map[string]*errorBaselineEntry),
+	}
+}
+
+func loadErrorBaseline(path string, window time.Duration) (*errorBaseline, error) {
+	eb := newErrorBaseline(path, window)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return eb, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, eb); err != nil {
+		return nil, err
+	}
+	return eb, nil
+}
+
+func (eb *errorBaseline) save() error {
+	eb.saveLck.Lock()
+	defer eb.saveLck.Unlock()
+	eb.lck.Lock()
+	data, err := json.Marshal(eb)
+	eb.lck.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := eb.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, eb.path)
+}
+
+func (eb *errorBaseline) setWindow(window time.Duration) {
+	eb.lck.Lock()
+	eb.window = window
+	eb.lck.Unlock()
+}
+
+// observe update the baseline with a template seen on node, when detect is set it return the events for a
+// template not seen on the node or its chain during the baseline window
+func (eb *errorBaseline) observe(tpl *ErrorTemplate, chain, node string, seen time.Time, detect bool) []NewErrorEvent {
+	eb.lck.Lock()
+	defer eb.lck.Unlock()
+	entry, ok := eb.Templates[tpl.Fingerprint.String()]
+	if !ok {
+		entry = &errorBaselineEntry{
+			Template: tpl.Template,
+			Nodes:    make(map[string]time.Time),
+			Chains:   make(map[string]time.Time),
+		}
+		eb.Templates[tpl.Fingerprint.String()] = entry
+	}
+	lastNode, lastChain := entry.Nodes[node], entry.Chains[chain]
+	if seen.After(lastNode) {
+		entry.Nodes[node] = seen
+	}
+	if seen.After(lastChain) {
+		entry.Chains[chain] = seen
+	}
+	if !detect || time.Since(eb.Started) < newErrorWarmup {
+		return nil
+	}
+	event := NewErrorEvent{Fingerprint: tpl.Fingerprint, Template: tpl.Template, Chain: chain, Time: seen}
+	var events []NewErrorEvent
+	if lastChain.IsZero() || seen.Sub(lastChain) > eb.window {
+		events = append(events, event)
+	} else if lastNode.IsZero() || seen.Sub(lastNode) > eb.window {
+		event.Node = node
+		events = append(events, event)
+	}
+	eb.recent = append(eb.recent, events...)
+	return events
+}
+
+// expire drop the events older than newErrorRecentWindow and the baseline entries older than the window
+func (eb *errorBaseline) expire() []NewErrorEvent {
+	eb.lck.Lock()
+	defer eb.lck.Unlock()
+	now := time.Now()
+	var expired, recent []NewErrorEvent
+	for _, event := range eb.recent {
+		if now.Sub(event.Time) > newErrorRecentWindow {
+			expired = append(expired, event)
+		} else {
+			recent = append(recent, event)
+		}
+	}
+	eb.recent = recent
+	for fingerprint, entry := range eb.Templates {
+		for chain, seen := range entry.Chains {
+			if now.Sub(seen) > eb.window {
+				delete(entry.Chains, chain)
+			}
+		}
+		for node, seen := range entry.Nodes {
+			if now.Sub(seen) > eb.window {
+				delete(entry.Nodes, node)
+			}
+		}
+		if len(entry.Chains) == 0 {
+			delete(eb.Templates, fingerprint)
+		}
+	}
+	return expired
+}
+
+// recentTemplates return the templates recently new for the node or its chain
+func (eb *errorBaseline) recentTemplates(chain, node string) []string {
+	eb.lck.Lock()
+	defer eb.lck.Unlock()
+	var result []string
+	for _, event := range eb.recent {
+		if event.Chain == chain && (event.Node == "" || event.Node == node) {
+			result = append(result, event.Template)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (event *NewErrorEvent) condition() string {
+	return alertNewError + ":" + event.Fingerprint.String()[:8]
+}
+
+func (lsrv *logTailService) reportNewErrors(events []NewErrorEvent) {
+	rd := lsrv.currentRedactor()
+	for _, event := range events {
+		var text string
+		if event.Node == "" {
+			text = fmt.Sprintf("New error on chain %v: %v 🆕", event.Chain, rd.redact(event.Template))
+		} else {
+			text = fmt.Sprintf("New error on node %v: %v 🆕", event.Node, rd.redact(event.Template))
+		}
+		lsrv.alerts.fire(event.Chain, event.Node, event.condition(), SeverityWarning, text)
+	}
+}
+
+func (lsrv *logTailService) watchNewErrors() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+	lastSave := time.Now()
+	for {
+		select {
+		case <-t.C:
+		case <-lsrv.stopping:
+			return
+		}
+		for _, event := range lsrv.errorBaseline.expire() {
+			lsrv.alerts.dismiss(event.Chain, event.Node, event.condition())
+		}
+		lsrv.errorCatalog.expire()
+		if time.Since(lastSave) > newErrorSaveInterval {
+			if err := lsrv.errorBaseline.save(); err != nil {
+				log.Println("save error baseline:", err)
+			}
+			lastSave = time.Now()
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+
+	// notifierTimeout bound every delivery so a hung endpoint only delay its own notifications
+	notifierTimeout = 15 * time.Second
+	// notifyQueueSize and dispatchQueueSize are the notifications and the batches waiting to be sent, the new
+	// ones are dropped when the queues are full
+	notifyQueueSize   = 1024
+	dispatchQueueSize = 16
+)
+
+var notifierClient = &http.Client{Timeout: notifierTimeout}
+
+type Notification struct {
+	Chain    string
+	Node     string
+	Severity string
+	Text     string
+	Time     time.Time
+}
+
+// Notifier deliver a batch of notifications to an external channel
+type Notifier interface {
+	Name() string
+	Notify(notis []Notification) error
+}
+
+type notifierConfig struct {
+	Name string
+	Type string
+	// slack, webhook, discord
+	URL string
+	// telegram
+	BotToken string
+	ChatID   string
+	// email
+	SMTPHost string
+	SMTPPort int
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string
+}
+
+// notifierRoute decide which notifiers receive a notification, empty Chains or Severities match everything
+type notifierRoute struct {
+	Notifiers  []string
+	Chains     []string
+	Severities []string
+}
+
+type notifierRouter struct {
+	notifiers map[string]Notifier
+	routes    []notifierRoute
+	// sent and failures count the batches per notifier
+	statsLck sync.Mutex
+	sent     map[string]int64
+	failures map[string]int64
+}
+
+func newNotifier(cfg notifierConfig) (Notifier, error) {
+	switch cfg.Type {
+	case "slack":
+		return &slackNotifier{name: cfg.Name, url: cfg.URL}, nil
+	case "webhook":
+		return &webhookNotifier{name: cfg.Name, url: cfg.URL}, nil
+	case "telegram":
+		return &telegramNotifier{name: cfg.Name, botToken: cfg.BotToken, chatID: cfg.ChatID}, nil
+	case "discord":
+		return &discordNotifier{name: cfg.Name, url: cfg.URL}, nil
+	case "email":
+		return &emailNotifier{name: cfg.Name, cfg: cfg}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %v of %v", cfg.Type, cfg.Name)
+}
+
+func newNotifierRouter(cfg *serviceConfig) (*notifierRouter, error) {
+	router := &notifierRouter{
+		notifiers: make(map[string]Notifier),
+		routes:    cfg.Routes,
+		sent:      make(map[string]int64),
+		failures:  make(map[string]int64),
+	}
+	for _, notiCfg := range cfg.Notifiers {
+		notifier, err := newNotifier(notiCfg)
+		if err != nil {
+			return nil, err
+		}
+		router.notifiers[notiCfg.Name] = notifier
+	}
+	for _, route := range cfg.Routes {
+		for _, name := range route.Notifiers {
+			if _, ok := router.notifiers[name]; !ok {
+				return nil, fmt.Errorf("route use unknown notifier %v", name)
+			}
+		}
+	}
+	return router, nil
+}
+
+func (route *notifierRoute) match(noti Notification) bool {
+	return matchAny(route.Chains, noti.Chain) && matchAny(route.Severities, noti.Severity)
+}
+
+func matchAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch send every notification to the notifiers its routes point to, one batch per notifier
+func (router *notifierRouter) dispatch(notis []Notification) {
+	batches := make(map[string][]Notification)
+	for _, noti := range notis {
+		sent := make(map[string]bool)
+		for _, route := range router.routes {
+			if !route.match(noti) {
+				continue
+			}
+			for _, name := range route.Notifiers {
+				if !sent[name] {
+					batches[name] = append(batches[name], noti)
+					sent[name] = true
+				}
+			}
+		}
+	}
+	for name, batch := range batches {
+		err := router.notifiers[name].Notify(batch)
+		router.statsLck.Lock()
+		router.sent[name]++
+		if err != nil {
+			router.failures[name]++
+		}
+		router.statsLck.Unlock()
+		if err != nil {
+			log.Printf("notifier %v failed: %v\n", name, err)
+		}
+	}
+}
+
+// inheritStats keep the counts of the notifiers of a previous router still configured so the counters don't reset
+// on reload
+func (router *notifierRouter) inheritStats(previous *notifierRouter) {
+	sent, failures := previous.stats()
+	router.statsLck.Lock()
+	defer router.statsLck.Unlock()
+	for name := range router.notifiers {
+		router.sent[name] = sent[name]
+		router.failures[name] = failures[name]
+	}
+}
+
+// stats return the number of batches sent and failed per notifier
+func (router *notifierRouter) stats() (sent, failures map[string]int64) {
+	router.statsLck.Lock()
+	defer router.statsLck.Unlock()
+	sent = make(map[string]int64)
+	failures = make(map[string]int64)
+	for name := range router.notifiers {
+		sent[name] = router.sent[name]
+		failures[name] = router.failures[name]
+	}
+	return sent, failures
+}
+
+func joinNotifications(notis []Notification) string {
+	var texts []string
+	for _, noti := range notis {
+		texts = append(texts, noti.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max-3] + "..."
+}
+
+// postJSON send content to endpoint, the errors don't hold endpoint as the bot tokens and webhook URLs are secrets
+func postJSON(endpoint string, content interface{}) error {
+	contentBytes, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	resp, err := notifierClient.Post(endpoint, "application/json", bytes.NewReader(contentBytes))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("post: %v", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%v: %v", resp.Status, string(body))
+	}
+	return nil
+}
+
+type slackNotifier struct {
+	name string
+	url  string
+}
+
+func (n *slackNotifier) Name() string { return n.name }
+
+func (n *slackNotifier) Notify(notis []Notification) error {
+	content := struct {
+		Text string `json:"text"`
+	}{
+		Text: joinNotifications(notis),
+	}
+	return postJSON(n.url, content)
+}
+
+type webhookNotifier struct {
+	name string
+	url  string
+}
+
+func (n *webhookNotifier) Name() string { return n.name }
+
+func (n *webhookNotifier) Notify(notis []Notification) error {
+	content := struct {
+		Notifications []Notification `json:"notifications"`
+	}{
+		Notifications: notis,
+	}
+	return postJSON(n.url, content)
+}
+
+type telegramNotifier struct {
+	name     string
+	botToken string
+	chatID   string
+}
+
+func (n *telegramNotifier) Name() string { return n.name }
+
+func (n *telegramNotifier) Notify(notis []Notification) error {
+	content := struct {
+		ChatID string `json:"chat_id"`
+		Text   string `json:"text"`
+	}{
+		ChatID: n.chatID,
+		Text:   truncateText(joinNotifications(notis), 4096),
+	}
+	return postJSON("https://api.telegram.org/bot"+n.botToken+"/sendMessage", content)
+}
+
+type discordNotifier struct {
+	name string
+	url  string
+}
+
+func (n *discordNotifier) Name() string { return n.name }
+
+func (n *discordNotifier) Notify(notis []Notification) error {
+	content := struct {
+		Content string `json:"content"`
+	}{
+		Content: truncateText(joinNotifications(notis), 2000),
+	}
+	return postJSON(n.url, content)
+}
+
+type emailNotifier struct {
+	name string
+	cfg  notifierConfig
+}
+
+func (n *emailNotifier) Name() string { return n.name }
+
+func (n *emailNotifier) Notify(notis []Notification) error {
+	subject := n.cfg.Subject
+	if subject == "" {
+		subject = "Incognito log viewer alerts"
+	}
+	msg := "From: " + n.cfg.From + "\r\n" +
+		"To: " + strings.Join(n.cfg.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + joinNotifications(notis) + "\r\n"
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.SMTPHost)
+	}
+	addr := n.cfg.SMTPHost + ":" + strconv.Itoa(n.cfg.SMTPPort)
+	return sendMail(addr, n.cfg.SMTPHost, auth, n.cfg.From, n.cfg.To, []byte(msg))
+}
+
+// sendMail is smtp.SendMail with the whole exchange bounded by notifierTimeout
+func sendMail(addr, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", addr, notifierTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(notifierTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostJSONErrorHideURL(t *testing.T) {
+	server := httptest.NewServer(nil)
+	endpoint := server.URL + "/bot123456:SECRETTOKEN/sendMessage"
+	server.Close()
+	err := postJSON(endpoint, map[string]string{"text": "hello"})
+	if err == nil {
+		t.Fatal("post to a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "SECRETTOKEN") {
+		t.Errorf("error leak the url: %v", err)
+	}
+}
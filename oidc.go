@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	oidcKeysRefreshInterval = time.Hour
+	// oidcKeysMinFetchInterval limit the fetches triggered by the tokens of unknown keys
+	oidcKeysMinFetchInterval = time.Minute
+)
+
+var errKeysFetchLimited = errors.New("keys fetched too recently")
+
+type oidcConfig struct {
+	Issuer   string
+	ClientID string
+	// JWKSURL is discovered from the issuer when empty
+	JWKSURL string
+	// UserClaim is the claim used as identity name, default to email then sub
+	UserClaim string
+}
+
+// oidcAuthenticator verify the RS256 ID tokens issued by an OpenID Connect provider
+type oidcAuthenticator struct {
+	cfg         oidcConfig
+	keysLck     sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+	// fetchLck serialize the fetches, lastFetch is the last attempt, failed or not
+	fetchLck  sync.Mutex
+	lastFetch time.Time
+}
+
+type oidcClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	Expiry    int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+	Email     string          `json:"email"`
+}
+
+func newOIDCAuthenticator(cfg oidcConfig) *oidcAuthenticator {
+	return &oidcAuthenticator{
+		cfg:  cfg,
+		keys: make(map[string]*rsa.PublicKey),
+	}
+}
+
+func getJSON(url string, v interface{}) error {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get %v: %v", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (oa *oidcAuthenticator) jwksURL() (string, error) {
+	if oa.cfg.JWKSURL != "" {
+		return oa.cfg.JWKSURL, nil
+	}
+	discovery := struct {
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+	if err := getJSON(strings.TrimSuffix(oa.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", err
+	}
+	return discovery.JWKSURI, nil
+}
+
+func (oa *oidcAuthenticator) fetchKeys() error {
+	url, err := oa.jwksURL()
+	if err != nil {
+		return err
+	}
+	jwks := struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}{}
+	if err := getJSON(url, &jwks); err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range jwks.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	oa.keysLck.Lock()
+	oa.keys = keys
+	oa.keysFetched = time.Now()
+	oa.keysLck.Unlock()
+	return nil
+}
+
+// key return the signing key of kid, the keys are fetched again when unknown or stale to follow rotations,
+// a cached key is still used when the provider can't be reached
+func (oa *oidcAuthenticator) key(kid string) (*rsa.PublicKey, error) {
+	oa.keysLck.RLock()
+	key, ok := oa.keys[kid]
+	stale := time.Since(oa.keysFetched) > oidcKeysRefreshInterval
+	oa.keysLck.RUnlock()
+	if ok && !stale {
+		return key, nil
+	}
+	fetchErr := oa.refreshKeys()
+	oa.keysLck.RLock()
+	defer oa.keysLck.RUnlock()
+	if key, ok := oa.keys[kid]; ok {
+		return key, nil
+	}
+	if fetchErr != nil && fetchErr != errKeysFetchLimited {
+		return nil, fetchErr
+	}
+	return nil, fmt.Errorf("unknown key %v", kid)
+}
+
+// refreshKeys fetch the keys at most once per oidcKeysMinFetchInterval so the tokens of unknown keys can't
+// make the service hammer the provider
+func (oa *oidcAuthenticator) refreshKeys() error {
+	oa.fetchLck.Lock()
+	defer oa.fetchLck.Unlock()
+	if time.Since(oa.lastFetch) < oidcKeysMinFetchInterval {
+		return errKeysFetchLimited
+	}
+	oa.lastFetch = time.Now()
+	if err := oa.fetchKeys(); err != nil {
+		log.Println("oidc keys fetch error: ", err)
+		return err
+	}
+	return nil
+}
+
+func (c *oidcClaims) hasAudience(clientID string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == clientID
+	}
+	var list []string
+	if json.Unmarshal(c.Audience, &list) == nil {
+		for _, aud := range list {
+			if aud == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (oa *oidcAuthenticator) verify(token string) (*oidcClaims, map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, errors.New("malformed token")
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, nil, fmt.Errorf("unsupported alg %v", header.Alg)
+	}
+	key, err := oa.key(header.Kid)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	claims := &oidcClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, nil, err
+	}
+	allClaims := make(map[string]interface{})
+	json.Unmarshal(payload, &allClaims)
+	now := time.Now().Unix()
+	if claims.Issuer != oa.cfg.Issuer {
+		return nil, nil, fmt.Errorf("unexpected issuer %v", claims.Issuer)
+	}
+	if !claims.hasAudience(oa.cfg.ClientID) {
+		return nil, nil, errors.New("unexpected audience")
+	}
+	if claims.Expiry == 0 || now >= claims.Expiry {
+		return nil, nil, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now < claims.NotBefore {
+		return nil, nil, errors.New("token not valid yet")
+	}
+	return claims, allClaims, nil
+}
+
+func (oa *oidcAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	token := requestToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	claims, allClaims, err := oa.verify(token)
+	if err != nil {
+		return nil, errInvalidCredentials
+	}
+	name := claims.Email
+	if oa.cfg.UserClaim != "" {
+		name, _ = allClaims[oa.cfg.UserClaim].(string)
+	}
+	if name == "" {
+		name = claims.Subject
+	}
+	return &Identity{Name: name, Method: "oidc"}, nil
+}
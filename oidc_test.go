@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testClientID = "logviewer"
+	testKid      = "key1"
+)
+
+// mockProvider serve the discovery document and the JWKS of an OpenID Connect provider
+type mockProvider struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	jwksHits   int32
+	jwksFailed int32
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp := &mockProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": mp.server.URL, "jwks_uri": mp.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&mp.jwksHits, 1)
+		if atomic.LoadInt32(&mp.jwksFailed) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": testKid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mp.server = httptest.NewServer(mux)
+	return mp
+}
+
+func (mp *mockProvider) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   mp.server.URL,
+		"sub":   "1234",
+		"aud":   testClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"email": "ops@example.com",
+	}
+}
+
+func (mp *mockProvider) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, mp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/api/chainstatus", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestOIDCAuthenticate(t *testing.T) {
+	mp := newMockProvider(t)
+	defer mp.server.Close()
+	oa := newOIDCAuthenticator(oidcConfig{Issuer: mp.server.URL, ClientID: testClientID})
+
+	identity, err := oa.Authenticate(bearerRequest(mp.sign(t, testKid, mp.claims())))
+	if err != nil || identity == nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+	if identity.Name != "ops@example.com" || identity.Method != "oidc" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	badSignature := mp.sign(t, testKid, mp.claims())
+	badSignature = badSignature[:len(badSignature)-4] + "AAAA"
+	cases := []struct {
+		name  string
+		token string
+	}{
+		{"expired", mp.sign(t, testKid, withClaim(mp.claims(), "exp", time.Now().Add(-time.Minute).Unix()))},
+		{"wrong audience", mp.sign(t, testKid, withClaim(mp.claims(), "aud", "other"))},
+		{"wrong issuer", mp.sign(t, testKid, withClaim(mp.claims(), "iss", "https://evil.example.com"))},
+		{"not valid yet", mp.sign(t, testKid, withClaim(mp.claims(), "nbf", time.Now().Add(time.Hour).Unix()))},
+		{"bad signature", badSignature},
+		{"unknown kid", mp.sign(t, "key2", mp.claims())},
+		{"malformed", "not.a-token"},
+	}
+	for _, c := range cases {
+		if identity, err := oa.Authenticate(bearerRequest(c.token)); err == nil || identity != nil {
+			t.Errorf("%v: token accepted", c.name)
+		}
+	}
+	if identity, err := oa.Authenticate(httptest.NewRequest("GET", "/", nil)); identity != nil || err != nil {
+		t.Errorf("request without token: %+v %v", identity, err)
+	}
+}
+
+func withClaim(claims map[string]interface{}, name string, value interface{}) map[string]interface{} {
+	claims[name] = value
+	return claims
+}
+
+func TestOIDCKeysFetchLimited(t *testing.T) {
+	mp := newMockProvider(t)
+	defer mp.server.Close()
+	oa := newOIDCAuthenticator(oidcConfig{Issuer: mp.server.URL, ClientID: testClientID})
+	if _, err := oa.Authenticate(bearerRequest(mp.sign(t, testKid, mp.claims()))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		oa.Authenticate(bearerRequest(mp.sign(t, "unknown", mp.claims())))
+	}
+	if hits := atomic.LoadInt32(&mp.jwksHits); hits != 1 {
+		t.Errorf("JWKS fetched %v times, expect 1", hits)
+	}
+
+	// the provider is down once the keys are stale, the cached key keep working
+	atomic.StoreInt32(&mp.jwksFailed, 1)
+	oa.keysLck.Lock()
+	oa.keysFetched = time.Now().Add(-2 * oidcKeysRefreshInterval)
+	oa.keysLck.Unlock()
+	oa.fetchLck.Lock()
+	oa.lastFetch = time.Time{}
+	oa.fetchLck.Unlock()
+	if _, err := oa.Authenticate(bearerRequest(mp.sign(t, testKid, mp.claims()))); err != nil {
+		t.Errorf("cached key rejected while the provider is down: %v", err)
+	}
+	if hits := atomic.LoadInt32(&mp.jwksHits); hits != 2 {
+		t.Errorf("JWKS fetched %v times, expect 2", hits)
+	}
+}
+
+func TestAuthServiceRequire(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pa55"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usersFile, err := ioutil.TempFile("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(usersFile.Name())
+	usersFile.WriteString("# users\nalice:" + string(hash) + "\n")
+	usersFile.Close()
+
+	as, err := newAuthService(&authConfig{Tokens: map[string]string{"s3cret": "ci-bot"}, BasicUsersFile: usersFile.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen *Identity
+	handler := as.require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIdentity(r)
+	}))
+
+	basic := func(user, password string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/chainstatus", nil)
+		r.SetBasicAuth(user, password)
+		return r
+	}
+	cases := []struct {
+		name     string
+		request  *http.Request
+		status   int
+		identity string
+	}{
+		{"bearer token", bearerRequest("s3cret"), http.StatusOK, "ci-bot"},
+		{"query token", httptest.NewRequest("GET", "/streamlog?token=s3cret", nil), http.StatusOK, "ci-bot"},
+		{"wrong token", bearerRequest("nope"), http.StatusUnauthorized, ""},
+		{"basic", basic("alice", "pa55"), http.StatusOK, "alice"},
+		{"basic wrong password", basic("alice", "nope"), http.StatusUnauthorized, ""},
+		{"basic unknown user", basic("bob", "pa55"), http.StatusUnauthorized, ""},
+		{"no credentials", httptest.NewRequest("GET", "/api/chainstatus", nil), http.StatusUnauthorized, ""},
+		{"exempt path", httptest.NewRequest("GET", "/healthz", nil), http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		seen = nil
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, c.request)
+		if w.Code != c.status {
+			t.Errorf("%v: status %v, expect %v", c.name, w.Code, c.status)
+			continue
+		}
+		if c.identity != "" && (seen == nil || seen.Name != c.identity) {
+			t.Errorf("%v: identity %+v, expect %v", c.name, seen, c.identity)
+		}
+		if c.status == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+			t.Errorf("%v: missing basic challenge", c.name)
+		}
+	}
+}
+
+func TestLoggedURLHideToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/streamlog?node=beacon0&token=s3cret", nil)
+	if logged := loggedURL(r); strings.Contains(logged, "s3cret") || !strings.Contains(logged, "node=beacon0") {
+		t.Errorf("logged url %v", logged)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	as, err := newAuthService(&authConfig{AllowedOrigins: []string{"https://ops.example.com/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := as.checkOrigin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(method, origin string) *http.Request {
+		r := httptest.NewRequest(method, "http://logviewer.local:8084/api/reload", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+	cases := []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{"same origin", request("POST", "http://logviewer.local:8084"), http.StatusOK},
+		{"allowed origin", request("POST", "https://ops.example.com"), http.StatusOK},
+		{"no origin", request("POST", ""), http.StatusOK},
+		{"foreign origin", request("POST", "https://evil.example.com"), http.StatusForbidden},
+		{"foreign origin read", request("GET", "https://evil.example.com"), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, c.request)
+		if w.Code != c.status {
+			t.Errorf("%v: status %v, expect %v", c.name, w.Code, c.status)
+		}
+	}
+	ws := request("GET", "https://evil.example.com")
+	if as.originAllowed(ws) {
+		t.Error("websocket of a foreign origin allowed")
+	}
+}
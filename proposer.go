@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type proposal struct {
+	height   int
+	timeslot int
+	round    int
+}
+
+// proposerStats count how the timeslots where a node was the proposer ended
+type proposerStats struct {
+	lck              sync.RWMutex
+	pending          []proposal
+	blocksProposed   int
+	slotsMissed      int
+	roundsOfProposed int
+}
+
+// reset forget the proposals counted, for a scan started again
+func (ps *proposerStats) reset() {
+	ps.lck.Lock()
+	ps.pending = nil
+	ps.blocksProposed, ps.slotsMissed, ps.roundsOfProposed = 0, 0, 0
+	ps.lck.Unlock()
+}
+
+type ProposerStats struct {
+	Node           string
+	Chain          string
+	BlocksProposed int
+	SlotsMissed    int
+	AvgRounds      float64
+}
+
+// addProposal remember a timeslot where the node entered the propose phase, the same timeslot is counted once
+func (ps *proposerStats) addProposal(height, timeslot, round int) {
+	ps.lck.Lock()
+	defer ps.lck.Unlock()
+	for _, p := range ps.pending {
+		if p.height == height && p.timeslot == timeslot {
+			return
+		}
+	}
+	ps.pending = append(ps.pending, proposal{height: height, timeslot: timeslot, round: round})
+}
+
+// settle is called when the node leave height, the block was committed in the last timeslot seen at that height
+// so a proposal in that timeslot produced the block and every other one missed its slot
+func (ps *proposerStats) settle(height, commitTimeslot int) {
+	ps.lck.Lock()
+	defer ps.lck.Unlock()
+	var pending []proposal
+	for _, p := range ps.pending {
+		if p.height > height {
+			pending = append(pending, p)
+			continue
+		}
+		if p.height == height && p.timeslot == commitTimeslot {
+			ps.blocksProposed++
+			ps.roundsOfProposed += p.round
+		} else {
+			ps.slotsMissed++
+		}
+	}
+	ps.pending = pending
+}
+
+func (ps *proposerStats) stats() (proposed, missed int, avgRounds float64) {
+	ps.lck.RLock()
+	defer ps.lck.RUnlock()
+	if ps.blocksProposed > 0 {
+		avgRounds = float64(ps.roundsOfProposed) / float64(ps.blocksProposed)
+	}
+	return ps.blocksProposed, ps.slotsMissed, avgRounds
+}
+
+func (lsrv *logTailService) GetProposerStats(chain, node string) []ProposerStats {
+	lsrv.currentTailerLck.RLock()
+	defer lsrv.currentTailerLck.RUnlock()
+	result := []ProposerStats{}
+	for key, tailer := range lsrv.currentTailer {
+		if (chain != "" && tailer.chain != chain) || (node != "" && key != node) {
+			continue
+		}
+		stats := ProposerStats{Node: key, Chain: tailer.chain}
+		stats.BlocksProposed, stats.SlotsMissed, stats.AvgRounds = tailer.proposerStats.stats()
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Chain == result[j].Chain {
+			ni, _ := strconv.Atoi(result[i].Node[len(result[i].Chain):])
+			nj, _ := strconv.Atoi(result[j].Node[len(result[j].Chain):])
+			return ni < nj
+		}
+		return result[i].Chain < result[j].Chain
+	})
+	return result
+}
+
+func proposerStatsHandler(lsrv *logTailService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		identity := requestIdentity(r)
+		result := []ProposerStats{}
+		for _, stats := range lsrv.GetProposerStats(r.URL.Query().Get("chain"), r.URL.Query().Get("node")) {
+			if identity.canReadNode(stats.Node) {
+				result = append(result, stats)
+			}
+		}
+		writeJSON(w, result)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const defaultRedactionReplacement = "[redacted]"
+
+type redactionRuleConfig struct {
+	Name    string
+	Pattern string
+	// Replacement default to [redacted], it can reference the groups of Pattern ($1)
+	Replacement string
+}
+
+// builtinRedactionRules hide the incognito keys, private keys are serialized with the 112t8r prefix and
+// payment addresses with the 12 prefix, both are long base58 strings
+var builtinRedactionRules = []redactionRuleConfig{
+	{Name: "incognito-private-key", Pattern: `\b112t8r[1-9a-z]{80,}\b`},
+	{Name: "incognito-payment-address", Pattern: `\b12[1-9a-z]{100,}\b`},
+}
+
+type redactionRule struct {
+	name        string
+	re          *regexp.Regexp
+	replacement string
+}
+
+// redactor rewrite the lines leaving the service, a nil redactor keep them as they are
+type redactor struct {
+	rules []*redactionRule
+}
+
+func newRedactor(cfgs []redactionRuleConfig) (*redactor, error) {
+	rd := &redactor{}
+	for _, cfg := range append(append([]redactionRuleConfig{}, builtinRedactionRules...), cfgs...) {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("redaction with pattern %v has no name", cfg.Pattern)
+		}
+		// lines are lowercased in some outbound paths (latest error line, alerts) so the rules are case insensitive
+		re, err := regexp.Compile("(?i)" + cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction %v: %v", cfg.Name, err)
+		}
+		rule := &redactionRule{name: cfg.Name, re: re, replacement: cfg.Replacement}
+		if rule.replacement == "" {
+			rule.replacement = defaultRedactionReplacement
+		}
+		rd.rules = append(rd.rules, rule)
+	}
+	return rd, nil
+}
+
+func (rd *redactor) redact(line string) string {
+	if rd == nil {
+		return line
+	}
+	for _, rule := range rd.rules {
+		line = rule.re.ReplaceAllString(line, rule.replacement)
+	}
+	return line
+}
+
+func (rd *redactor) redactLines(lines []string) []string {
+	if rd == nil {
+		return lines
+	}
+	for i := range lines {
+		lines[i] = rd.redact(lines[i])
+	}
+	return lines
+}
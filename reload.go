@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReloadResult tell what a reload changed
+type ReloadResult struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	Notifiers    int
+	Rules        int
+	Redactions   int
+	// RestartRequired list the changed settings that are read at startup only
+	RestartRequired []string `json:",omitempty"`
+}
+
+// configReloader apply the config file again to the running service, the hubs of the nodes kept and their
+// clients stay connected
+type configReloader struct {
+	lck    sync.Mutex
+	path   string
+	config *serviceConfig
+	lsrv   *logTailService
+}
+
+func newConfigReloader(path string, config *serviceConfig, lsrv *logTailService) *configReloader {
+	return &configReloader{path: path, config: config, lsrv: lsrv}
+}
+
+func (lsrv *logTailService) currentNotifier() *notifierRouter {
+	lsrv.configLck.RLock()
+	defer lsrv.configLck.RUnlock()
+	return lsrv.notifier
+}
+
+func (lsrv *logTailService) currentRules() []*patternRule {
+	lsrv.configLck.RLock()
+	defer lsrv.configLck.RUnlock()
+	return lsrv.rules
+}
+
+func (lsrv *logTailService) currentRedactor() *redactor {
+	lsrv.configLck.RLock()
+	defer lsrv.configLck.RUnlock()
+	return lsrv.redactor
+}
+
+// reload read the config file, nothing is applied when a part of it is invalid
+func (cr *configReloader) reload() (*ReloadResult, error) {
+	cr.lck.Lock()
+	defer cr.lck.Unlock()
+	config, err := loadConfig(cr.path)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := config.topology()
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := cr.lsrv.replay.notifierRouter(config)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := newPatternRules(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+	redactor, err := newRedactor(config.Redactions)
+	if err != nil {
+		return nil, err
+	}
+	baselineWindow, err := config.newErrorBaseline()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Notifiers: len(notifier.notifiers), Rules: len(rules), Redactions: len(config.Redactions)}
+	// the authenticators are wrapped around the server handler at startup
+	if !reflect.DeepEqual(config.Auth, cr.config.Auth) {
+		result.RestartRequired = append(result.RestartRequired, "Auth")
+	}
+	lsrv := cr.lsrv
+	notifier.inheritStats(lsrv.currentNotifier())
+	lsrv.configLck.Lock()
+	lsrv.notifier, lsrv.rules, lsrv.redactor = notifier, rules, redactor
+	lsrv.configLck.Unlock()
+	lsrv.errorBaseline.setWindow(baselineWindow)
+	lsrv.dismissRemovedRules(rules)
+	result.AddedNodes, result.RemovedNodes = lsrv.resizeNodes(nodes)
+	cr.config = config
+	log.Printf("config reloaded: %v notifiers, %v rules, nodes added %v, removed %v\n", result.Notifiers, result.Rules, result.AddedNodes, result.RemovedNodes)
+	if len(result.RestartRequired) > 0 {
+		log.Printf("config change of %v needs a restart\n", strings.Join(result.RestartRequired, ", "))
+	}
+	return result, nil
+}
+
+// dismissRemovedRules dismiss the alerts of the rules no longer configured, nothing would resolve them
+func (lsrv *logTailService) dismissRemovedRules(rules []*patternRule) {
+	conditions := make(map[string]bool)
+	for _, rule := range rules {
+		conditions[rule.condition()] = true
+	}
+	lsrv.alerts.dismissMatching(func(chain, node, condition string) bool {
+		return strings.HasPrefix(condition, "rule:") && !conditions[condition]
+	})
+}
+
+// resizeNodes start the tailers of the nodes added to the network and stop the ones of the nodes removed
+func (lsrv *logTailService) resizeNodes(nodes nodesConfig) (added, removed []string) {
+	previous := make(map[string]bool)
+	for _, node := range nodeList() {
+		previous[node.chain+strconv.Itoa(node.number)] = true
+	}
+	setTopology(nodes)
+	// the new nodes wait for their file when the directory can't be read
+	files, err := ioutil.ReadDir(lsrv.logDir)
+	if err != nil {
+		log.Println("read log dir:", err)
+	}
+	date := lsrv.logDate()
+	for _, node := range nodeList() {
+		key := node.chain + strconv.Itoa(node.number)
+		if previous[key] {
+			delete(previous, key)
+			continue
+		}
+		lsrv.startNode(node.chain, node.number, date, files)
+		added = append(added, key)
+	}
+	for key := range previous {
+		lsrv.stopNode(key)
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+	return added, removed
+}
+
+func reloadHandler(cr *configReloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !requestIdentity(r).hasPermission(permReload) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		result, err := cr.reload()
+		if err != nil {
+			http.Error(w, "Config not reloaded: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, result)
+	}
+}
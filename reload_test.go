@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitRunningHubs wait for the number of goroutines running a hub to be expect
+func waitRunningHubs(t *testing.T, expect int) {
+	t.Helper()
+	buf := make([]byte, 1<<20)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		running := strings.Count(string(buf[:runtime.Stack(buf, true)]), "(*Hub).run(")
+		if running == expect {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%v hubs running, expect %v", running, expect)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestReloadRemoveNode shrink the topology and check the hub of the removed node is stopped
+func TestReloadRemoveNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(configPath, []byte(`{"Nodes": {"BeaconNodes": 1, "Shards": 1, "NodesPerShard": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lsrv, lHub, stop := startTestService(t, dir, nodesConfig{BeaconNodes: 2, Shards: 1, NodesPerShard: 1})
+	defer stop()
+	if lsrv.notifier, err = newNotifierRouter(&serviceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	// the status hub and the hubs of beacon0, beacon1 and shard00
+	waitRunningHubs(t, 4)
+
+	result, err := newConfigReloader(configPath, &serviceConfig{}, lsrv).reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.RemovedNodes) != 1 || result.RemovedNodes[0] != "beacon1" || len(result.AddedNodes) != 0 {
+		t.Fatalf("reload added %v, removed %v", result.AddedNodes, result.RemovedNodes)
+	}
+	if _, ok := lHub.get("beacon1"); ok {
+		t.Error("hub of beacon1 still registered")
+	}
+	waitRunningHubs(t, 3)
+}
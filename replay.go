@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// replayBufferLines is how many of the latest replayed lines a tailer keep for the streams asking for lines
+const replayBufferLines = 10000
+
+// replayConfig make the tailers re-emit the log of a past date at its original pace divided by speed
+type replayConfig struct {
+	date  string
+	speed float64
+	// notify send the alerts to the configured notifiers, a replay notify nobody by default
+	notify bool
+}
+
+// notifierRouter build the router of the config, it has no notifier when replaying without notify. The config
+// is checked all the same
+func (replay *replayConfig) notifierRouter(cfg *serviceConfig) (*notifierRouter, error) {
+	router, err := newNotifierRouter(cfg)
+	if err != nil || replay == nil || replay.notify {
+		return router, err
+	}
+	return newNotifierRouter(&serviceConfig{})
+}
+
+// replayLog read the log file from the start and process every line as tailLog would, waiting between
+// lines as long as their timestamps tell
+func (l *logTail) replayLog() {
+	fileHandle, err := os.OpenFile(l.logDir+"/"+l.currentFile().Name(), os.O_RDONLY, 0666)
+	if err != nil {
+		log.Println("Cannot open file", err)
+		return
+	}
+	defer fileHandle.Close()
+	speed := l.logService.replay.speed
+	l.isLive = true
+	lineCount := 1
+	scanner := newLogLineReader(fileHandle)
+	var previous time.Time
+	for scanner.Scan() {
+		line := scanner.Text()
+		if lineTime, ok := parseLogLineTime(line); ok {
+			if !previous.IsZero() && lineTime.After(previous) {
+				select {
+				case <-time.After(time.Duration(float64(lineTime.Sub(previous)) / speed)):
+				case <-l.logService.stopping:
+					return
+				case <-l.stop:
+					return
+				}
+			}
+			previous = lineTime
+		}
+		lineCount++
+		l.replayed.add(line)
+		l.processLine(line, lineCount)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Println("replay of", l.nodeKey(), "failed at line", lineCount, err)
+		return
+	}
+	log.Println("replay of", l.nodeKey(), "finished")
+}
+
+// replayBuffer keep the latest lines a replay reached, the end of the file is still ahead of the replay
+type replayBuffer struct {
+	lck   sync.Mutex
+	lines []string
+	next  int
+}
+
+func newReplayBuffer() *replayBuffer {
+	return &replayBuffer{lines: make([]string, 0, replayBufferLines)}
+}
+
+func (rb *replayBuffer) add(line string) {
+	rb.lck.Lock()
+	defer rb.lck.Unlock()
+	if len(rb.lines) < replayBufferLines {
+		rb.lines = append(rb.lines, line)
+		return
+	}
+	rb.lines[rb.next] = line
+	rb.next = (rb.next + 1) % replayBufferLines
+}
+
+// latest return up to n lines, the latest first as RetrieveLineFromEOF
+func (rb *replayBuffer) latest(n int) []string {
+	rb.lck.Lock()
+	defer rb.lck.Unlock()
+	if n > len(rb.lines) {
+		n = len(rb.lines)
+	}
+	result := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		result = append(result, rb.lines[(rb.next-i+len(rb.lines))%len(rb.lines)])
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestReplayBufferLatest(t *testing.T) {
+	rb := newReplayBuffer()
+	if latest := rb.latest(5); len(latest) != 0 {
+		t.Errorf("empty buffer returned %v", latest)
+	}
+	rb.add("a")
+	rb.add("b")
+	if latest := rb.latest(5); !reflect.DeepEqual(latest, []string{"b", "a"}) {
+		t.Errorf("latest %v", latest)
+	}
+	for i := 0; i < replayBufferLines+3; i++ {
+		rb.add(strconv.Itoa(i))
+	}
+	last := strconv.Itoa(replayBufferLines + 2)
+	if latest := rb.latest(2); !reflect.DeepEqual(latest, []string{last, strconv.Itoa(replayBufferLines + 1)}) {
+		t.Errorf("latest after wrap %v", latest)
+	}
+	if latest := rb.latest(replayBufferLines + 10); len(latest) != replayBufferLines || latest[replayBufferLines-1] != "3" {
+		t.Errorf("%v lines, oldest %v", len(latest), latest[len(latest)-1])
+	}
+}
+
+func TestReplayNotifierRouter(t *testing.T) {
+	cfg := &serviceConfig{Notifiers: []notifierConfig{{Name: "ops", Type: "slack", URL: "https://hooks.example.com/secret"}}}
+	cases := []struct {
+		name   string
+		replay *replayConfig
+		expect int
+	}{
+		{"live", nil, 1},
+		{"replay", &replayConfig{date: "2026-10-18", speed: 1}, 0},
+		{"replay with notify", &replayConfig{date: "2026-10-18", speed: 1, notify: true}, 1},
+	}
+	for _, c := range cases {
+		router, err := c.replay.notifierRouter(cfg)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if len(router.notifiers) != c.expect {
+			t.Errorf("%v: %v notifiers, expect %v", c.name, len(router.notifiers), c.expect)
+		}
+	}
+	invalid := &serviceConfig{Routes: []notifierRoute{{Notifiers: []string{"missing"}}}}
+	if _, err := (&replayConfig{date: "2026-10-18", speed: 1}).notifierRouter(invalid); err == nil {
+		t.Error("invalid config accepted by a replay")
+	}
+}
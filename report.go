@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// reportGapThreshold is the silence between two lines of a node reported as a gap
+const reportGapThreshold = time.Minute
+
+type LogReport struct {
+	Dir          string
+	Date         string
+	Generated    time.Time
+	Nodes        []NodeReport
+	MissingNodes []string
+	Chains       []ChainReport
+	TopErrors    []ErrorTemplate
+	Alerts       []string
+}
+
+type NodeReport struct {
+	Node              string
+	Chain             string
+	File              string
+	Lines             int
+	FirstHeight       int
+	LastHeight        int
+	Heights           int
+	MaxRound          int
+	AvgRound          float64
+	MultiRoundHeights int
+	ErrorsCount       int
+	LevelCounts       LevelCounts
+	PhaseDurations    map[string]float64
+	Gaps              []LogGap
+	BlocksProposed    int
+	SlotsMissed       int
+	AvgProposeRounds  float64
+	// Error is why the log of the node couldn't be read to the end, the other nodes are still reported
+	Error string `json:",omitempty"`
+}
+
+type nodeRef struct {
+	chain  string
+	number int
+}
+
+func nodeList() []nodeRef {
+	var nodes []nodeRef
+	topology := currentTopology()
+	for i := 0; i <= topology.BeaconNodes-1; i++ {
+		nodes = append(nodes, nodeRef{chain: "beacon", number: i})
+	}
+	for s := 0; s <= topology.Shards-1; s++ {
+		for i := 0; i <= topology.NodesPerShard-1; i++ {
+			nodes = append(nodes, nodeRef{chain: "shard" + strconv.Itoa(s), number: i})
+		}
+	}
+	return nodes
+}
+
+type LogGap struct {
+	From    time.Time
+	To      time.Time
+	Seconds float64
+}
+
+type ChainReport struct {
+	Chain       string
+	BlockHeight int
+	BlockTiming BlockTimingStats
+	Forks       []HeightDivergence
+	CrossLink   *ShardCrossLink `json:",omitempty"`
+}
+
+// runReport is the report subcommand, it parse a directory of logs offline and write report.json and report.html
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	var logdir = fs.String("dir", "./", "logs directory")
+	var date = fs.String("date", time.Now().Format("2006-01-02"), "date of the logs to analyze (YYYY-MM-DD)")
+	var outdir = fs.String("out", "./", "directory to write report.json and report.html")
+	fs.Parse(args)
+
+	report, err := buildReport(*logdir, *date)
+	if err != nil {
+		log.Fatal("buildReport: ", err)
+	}
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*outdir, "report.json"), reportBytes, 0644); err != nil {
+		log.Fatal(err)
+	}
+	htmlFile, err := os.Create(filepath.Join(*outdir, "report.html"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer htmlFile.Close()
+	if err := reportTemplate.Execute(htmlFile, report); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("report of %v nodes written to %v\n", len(report.Nodes), *outdir)
+}
+
+func buildReport(logDir, date string) (*LogReport, error) {
+	files, err := ioutil.ReadDir(logDir)
+	if err != nil {
+		return nil, err
+	}
+	report := &LogReport{
+		Dir:       logDir,
+		Date:      date,
+		Generated: time.Now(),
+	}
+	lsrv := &logTailService{}
+	lsrv.initTrackers()
+	lsrv.errorBaseline = newErrorBaseline("", defaultNewErrorBaseline)
+	lsrv.alerts = newAlertManager(func(chain, node, severity, text string) {
+		report.Alerts = append(report.Alerts, text)
+	}, nil)
+
+	for _, node := range nodeList() {
+		filePrefix, fileSuffix := getLogFileNameOfDate(node.chain, node.number, date)
+		logFile := getLogFileForFileList(filePrefix, fileSuffix, files)
+		if logFile == nil {
+			report.MissingNodes = append(report.MissingNodes, node.chain+strconv.Itoa(node.number))
+			continue
+		}
+		l := &logTail{
+			chain:      node.chain,
+			nodeNumber: node.number,
+			logDir:     logDir,
+			file:       logFile,
+			logService: lsrv,
+			ruleHits:   ruleHits{hits: make(map[string][]time.Time)},
+		}
+		lsrv.addLogStreamer(l.nodeKey(), l)
+		nodeReport, err := l.scanReport()
+		if err != nil {
+			log.Println("report of", l.nodeKey(), "failed:", err)
+			nodeReport.Error = err.Error()
+		}
+		report.Nodes = append(report.Nodes, nodeReport)
+	}
+
+	for _, chain := range chainList() {
+		chainReport := ChainReport{
+			Chain:       chain,
+			BlockHeight: lsrv.getBlockHeight(chain),
+			BlockTiming: lsrv.blockTiming.stats(chain, false),
+			Forks:       lsrv.forkTracker.divergences(chain, false),
+		}
+		if chain != "beacon" {
+			shardID, _ := strconv.Atoi(chain[len("shard"):])
+			crossLink := lsrv.GetShardCrossLink(shardID)
+			chainReport.CrossLink = &crossLink
+		}
+		report.Chains = append(report.Chains, chainReport)
+	}
+	report.TopErrors = lsrv.errorCatalog.topErrors(func(string, string) bool { return true }, defaultTopErrorsLimit)
+	return report, nil
+}
+
+// scanReport run readLogLine over the whole file and summarize the node
+func (l *logTail) scanReport() (NodeReport, error) {
+	result := NodeReport{
+		Node:           l.nodeKey(),
+		Chain:          l.chain,
+		File:           l.file.Name(),
+		PhaseDurations: make(map[string]float64),
+	}
+	l.heightsRecord = make(map[int]*heightRecord)
+	fileHandle, err := os.OpenFile(l.logDir+"/"+l.file.Name(), os.O_RDONLY, 0666)
+	if err != nil {
+		return result, err
+	}
+	defer fileHandle.Close()
+	lineCount := 1
+	scanner := newLogLineReader(fileHandle)
+	var lastTime, phaseTime time.Time
+	phase := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineCount++
+		l.readLogLine(line, lineCount, true)
+		lineTime, ok := parseLogLineTime(line)
+		if !ok {
+			continue
+		}
+		if !lastTime.IsZero() && lineTime.Sub(lastTime) > reportGapThreshold {
+			result.Gaps = append(result.Gaps, LogGap{From: lastTime, To: lineTime, Seconds: lineTime.Sub(lastTime).Seconds()})
+		}
+		lastTime = lineTime
+		if current := l.latestBlockProducingStatus.Phase; current != phase {
+			if phase != "" {
+				result.PhaseDurations[phase] += lineTime.Sub(phaseTime).Seconds()
+			}
+			phase, phaseTime = current, lineTime
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("%v line %v: %v", l.file.Name(), lineCount, err)
+	}
+
+	result.Lines = lineCount - 1
+	result.ErrorsCount = l.errorsCount
+	result.LevelCounts = l.levelCounts
+	result.BlocksProposed, result.SlotsMissed, result.AvgProposeRounds = l.proposerStats.stats()
+	var heights []int
+	for h := range l.heightsRecord {
+		heights = append(heights, h)
+	}
+	sort.Ints(heights)
+	totalRound := 0
+	for _, h := range heights {
+		round := l.heightsRecord[h].round
+		totalRound += round
+		if round > result.MaxRound {
+			result.MaxRound = round
+		}
+		if round > 1 {
+			result.MultiRoundHeights++
+		}
+	}
+	if len(heights) > 0 {
+		result.FirstHeight = heights[0]
+		result.LastHeight = heights[len(heights)-1]
+		result.Heights = len(heights)
+		result.AvgRound = float64(totalRound) / float64(len(heights))
+	}
+	return result, nil
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Log report {{.Date}}</title>
+<style>
+body { font-family: sans-serif; font-size: 13px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 3px 8px; text-align: right; }
+th { background: #eee; }
+td.text { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Log report {{.Date}}</h1>
+<p>Directory {{.Dir}}, generated {{.Generated.Format "2006-01-02 15:04:05"}}</p>
+
+<h2>Chains</h2>
+<table>
+<tr><th>Chain</th><th>Height</th><th>Avg interval (s)</th><th>Min</th><th>Max</th><th>Missed timeslots</th><th>Forks</th><th>Beacon lag</th></tr>
+{{range .Chains}}<tr><td class="text">{{.Chain}}</td><td>{{.BlockHeight}}</td><td>{{printf "%.2f" .BlockTiming.AvgInterval}}</td><td>{{printf "%.2f" .BlockTiming.MinInterval}}</td><td>{{printf "%.2f" .BlockTiming.MaxInterval}}</td><td>{{.BlockTiming.MissedTimeslots}}</td><td>{{len .Forks}}</td><td>{{if .CrossLink}}{{.CrossLink.BeaconLag}}{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Nodes</h2>
+<table>
+<tr><th>Node</th><th>Lines</th><th>Heights</th><th>First</th><th>Last</th><th>Max round</th><th>Avg round</th><th>Multi round</th><th>Errors</th><th>Warns</th><th>Proposed</th><th>Missed slots</th><th>Gaps</th><th>Phases (s)</th></tr>
+{{range .Nodes}}<tr><td class="text">{{.Node}}</td><td>{{.Lines}}</td><td>{{.Heights}}</td><td>{{.FirstHeight}}</td><td>{{.LastHeight}}</td><td>{{.MaxRound}}</td><td>{{printf "%.2f" .AvgRound}}</td><td>{{.MultiRoundHeights}}</td><td>{{.ErrorsCount}}</td><td>{{.LevelCounts.Warn}}</td><td>{{.BlocksProposed}}</td><td>{{.SlotsMissed}}</td><td class="text">{{range .Gaps}}{{.From.Format "15:04:05"}} - {{.To.Format "15:04:05"}}<br>{{end}}</td><td class="text">{{range $phase, $seconds := .PhaseDurations}}{{$phase}}: {{printf "%.0f" $seconds}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{if .MissingNodes}}<p>No log found for: {{range .MissingNodes}}{{.}} {{end}}</p>{{end}}
+{{range .Nodes}}{{if .Error}}<p>Cannot read the log of {{.Node}}: {{.Error}}</p>{{end}}{{end}}
+
+<h2>Top errors</h2>
+<table>
+<tr><th>Count</th><th>Nodes</th><th>Template</th></tr>
+{{range .TopErrors}}<tr><td>{{.Count}}</td><td>{{len .Nodes}}</td><td class="text">{{.Template}}</td></tr>
+{{end}}</table>
+
+{{if .Alerts}}<h2>Alerts</h2>
+<ul>{{range .Alerts}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
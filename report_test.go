@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReportUnreadableNode check a node log that can't be read is reported with its error and the other nodes
+// are still reported
+func TestReportUnreadableNode(t *testing.T) {
+	withTopology(t, nodesConfig{BeaconNodes: 2})
+	dir, err := ioutil.TempDir("", "logviewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a directory named as the log can be opened but not read
+	if err := os.Mkdir(filepath.Join(dir, "beacon0_fullnode_1.2.3.4_2026-10-19.log"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	lines := append(consensusLines(1), consensusLines(2)...)
+	if err := ioutil.WriteFile(filepath.Join(dir, "beacon1_fullnode_1.2.3.5_2026-10-19.log"), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report, err := buildReport(dir, "2026-10-19")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Nodes) != 2 {
+		t.Fatalf("%v nodes reported, expect 2", len(report.Nodes))
+	}
+	if report.Nodes[0].Node != "beacon0" || report.Nodes[0].Error == "" {
+		t.Errorf("unreadable node %+v", report.Nodes[0])
+	}
+	if report.Nodes[1].Error != "" || report.Nodes[1].Heights != 2 {
+		t.Errorf("readable node %+v", report.Nodes[1])
+	}
+}
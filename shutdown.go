@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const shutdownTimeout = 15 * time.Second
+
+var errShutdownTimeout = errors.New("shutdown deadline exceeded")
+
+// waitGroupContext wait for wg until ctx is done
+func waitGroupContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errShutdownTimeout
+	}
+}
+
+// Shutdown stop every tailer then persist the state kept in memory, the state is persisted even when the
+// tailers don't stop before ctx is done
+func (lsrv *logTailService) Shutdown(ctx context.Context) error {
+	lsrv.tailersLck.Lock()
+	lsrv.stopped = true
+	close(lsrv.stopping)
+	lsrv.tailersLck.Unlock()
+	err := waitGroupContext(ctx, &lsrv.tailersWG)
+	if err != nil {
+		log.Println("tailers not stopped:", err)
+	}
+	if flushErr := lsrv.flushNotifications(ctx); flushErr != nil {
+		log.Println("notifications not flushed:", flushErr)
+	}
+	if saveErr := lsrv.errorBaseline.save(); saveErr != nil {
+		log.Println("save error baseline:", saveErr)
+		err = saveErr
+	}
+	if lsrv.alertHistory != nil {
+		lsrv.alerts.flushHistory()
+		if closeErr := lsrv.alertHistory.close(); closeErr != nil {
+			log.Println("close alert store:", closeErr)
+			err = closeErr
+		}
+	}
+	return err
+}
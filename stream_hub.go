@@ -1,8 +1,15 @@
 package main
 
+import "sync/atomic"
+
 // Hub maintains the set of active clients and broadcasts messages to the
 // clients.
 type Hub struct {
+	// clientCount and dropped are read by the metrics, dropped count the messages lost by the slow clients
+	// disconnected for it. They are first to stay 64-bit aligned for atomic.
+	clientCount int64
+	dropped     int64
+
 	// Registered clients.
 	clients map[*LogStreamer]bool
 
@@ -14,6 +21,9 @@ type Hub struct {
 
 	// Unregister requests from clients.
 	unregister chan *LogStreamer
+
+	// quit close every client and stop the hub, the senders select on it so they don't block afterward.
+	quit chan struct{}
 }
 
 func newHub() *Hub {
@@ -21,6 +31,7 @@ func newHub() *Hub {
 		broadcast:  make(chan []byte),
 		register:   make(chan *LogStreamer),
 		unregister: make(chan *LogStreamer),
+		quit:       make(chan struct{}),
 		clients:    make(map[*LogStreamer]bool),
 	}
 }
@@ -30,20 +41,63 @@ func (h *Hub) run() {
 		select {
 		case client := <-h.register:
 			h.clients[client] = true
+			atomic.StoreInt64(&h.clientCount, int64(len(h.clients)))
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
+				atomic.StoreInt64(&h.clientCount, int64(len(h.clients)))
 			}
 		case message := <-h.broadcast:
 			for client := range h.clients {
+				if client.filter != nil && !client.filter(message) {
+					continue
+				}
 				select {
 				case client.send <- message:
 				default:
 					close(client.send)
 					delete(h.clients, client)
+					atomic.AddInt64(&h.dropped, 1)
 				}
 			}
+			atomic.StoreInt64(&h.clientCount, int64(len(h.clients)))
+		case <-h.quit:
+			// closing send make writePump send the close frame
+			for client := range h.clients {
+				close(client.send)
+				delete(h.clients, client)
+			}
+			atomic.StoreInt64(&h.clientCount, 0)
+			return
 		}
 	}
 }
+
+func (h *Hub) close() {
+	close(h.quit)
+}
+
+// add register a client, its send channel is closed right away when the hub is closed
+func (h *Hub) add(client *LogStreamer) {
+	select {
+	case h.register <- client:
+	case <-h.quit:
+		close(client.send)
+	}
+}
+
+func (h *Hub) leave(client *LogStreamer) {
+	select {
+	case h.unregister <- client:
+	case <-h.quit:
+	}
+}
+
+// send broadcast a message to the clients, it is dropped when the hub is closed
+func (h *Hub) send(message []byte) {
+	select {
+	case h.broadcast <- message:
+	case <-h.quit:
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = 2 * time.Minute
+
+	alertTailerDegraded = "degraded"
+)
+
+// tailerHealth is the error that stopped the tailer of a node, the node is degraded until the tailer run again
+type tailerHealth struct {
+	lck           sync.RWMutex
+	degradedErr   string
+	degradedSince time.Time
+}
+
+// supervise run the scan and the tail of the node, restarting them with backoff when they fail instead of
+// taking the whole service down
+func (l *logTail) supervise() {
+	backoff := supervisorMinBackoff
+	for {
+		started := time.Now()
+		err := l.runTailer()
+		if err == nil {
+			return
+		}
+		l.setDegraded(err)
+		// a tailer that ran for a while failed for a new reason, retry quickly
+		if time.Since(started) > supervisorMaxBackoff {
+			backoff = supervisorMinBackoff
+		}
+		log.Printf("tailer of %v failed: %v, restarting in %v\n", l.nodeKey(), err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-l.logService.stopping:
+			return
+		case <-l.stop:
+			return
+		}
+		backoff *= 2
+		if backoff > supervisorMaxBackoff {
+			backoff = supervisorMaxBackoff
+		}
+	}
+}
+
+// runTailer scan the file once then tail it, it return nil only when the service stop
+func (l *logTail) runTailer() error {
+	if !l.scanned {
+		started := time.Now()
+		err := l.scanLog()
+		select {
+		case <-l.stop:
+			// the node was removed from the config during the scan
+			return nil
+		default:
+		}
+		l.logService.recordInitialScan(l.nodeKey(), time.Since(started))
+		if err != nil {
+			return fmt.Errorf("scan %v: %v", l.currentFile().Name(), err)
+		}
+		l.scanned = true
+	}
+	if err := l.tailLog(); err != nil {
+		return fmt.Errorf("tail %v: %v", l.currentFile().Name(), err)
+	}
+	return nil
+}
+
+func (l *logTail) setDegraded(err error) {
+	l.health.lck.Lock()
+	if l.health.degradedErr == "" {
+		l.health.degradedSince = time.Now()
+	}
+	l.health.degradedErr = err.Error()
+	l.health.lck.Unlock()
+	l.logService.alerts.fire(l.chain, l.nodeKey(), alertTailerDegraded, SeverityCritical, fmt.Sprintf("Node %v log unreadable: %v 🚧", l.nodeKey(), err))
+}
+
+func (l *logTail) setHealthy() {
+	l.health.lck.Lock()
+	wasDegraded := l.health.degradedErr != ""
+	l.health.degradedErr = ""
+	l.health.degradedSince = time.Time{}
+	l.health.lck.Unlock()
+	if wasDegraded {
+		l.logService.alerts.resolve(l.chain, l.nodeKey(), alertTailerDegraded, fmt.Sprintf("Node %v log readable again 🎉", l.nodeKey()))
+	}
+}
+
+// degraded return the error of a degraded node, an empty string when the tailer run fine
+func (l *logTail) degraded() (string, time.Time) {
+	l.health.lck.RLock()
+	defer l.health.lck.RUnlock()
+	return l.health.degradedErr, l.health.degradedSince
+}
@@ -3,23 +3,26 @@ package main
 import (
 	"encoding/json"
 	"net/http"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+// diskLeft is the free space in GB, it is written by watchDiskUsage and read by the handler with atomic
 var diskLeft uint64
 
 type systemInfo struct {
 	Diskleft uint64
 }
 
-func watchDiskUsage(dir string) {
+func watchDiskUsage(dir string, lsrv *logTailService) {
 	for {
 		var stat syscall.Statfs_t
 		syscall.Statfs(dir, &stat)
-		diskLeft = stat.Bavail * uint64(stat.Bsize) / 1000000000
-		if diskLeft <= 2 {
-			sendSlackNoti("Low disk!!!")
+		left := stat.Bavail * uint64(stat.Bsize) / 1000000000
+		atomic.StoreUint64(&diskLeft, left)
+		if left <= 2 {
+			lsrv.currentNotifier().dispatch([]Notification{{Severity: SeverityCritical, Text: "Low disk!!!", Time: time.Now()}})
 		}
 		time.Sleep(30 * time.Minute)
 	}
@@ -32,7 +35,7 @@ func diskLeftHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	sys := systemInfo{
-		Diskleft: diskLeft,
+		Diskleft: atomic.LoadUint64(&diskLeft),
 	}
 	sysBytes, err := json.Marshal(sys)
 	if err != nil {
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const certReloadInterval = 30 * time.Second
+
+// certReloader serve the certificate of certFile and keyFile, it load them again when one of them change on disk
+type certReloader struct {
+	certFile string
+	keyFile  string
+	lck      sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	go cr.watch()
+	return cr, nil
+}
+
+// latestModTime return the most recent modification time of the cert and key files
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (cr *certReloader) load() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.lck.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.lck.Unlock()
+	return nil
+}
+
+// watch poll the files, a failed reload keep the current certificate so a half written renewal is retried later
+func (cr *certReloader) watch() {
+	t := time.NewTicker(certReloadInterval)
+	for {
+		<-t.C
+		modTime, err := cr.latestModTime()
+		if err != nil {
+			log.Println("certificate stat error: ", err)
+			continue
+		}
+		cr.lck.RLock()
+		changed := !modTime.Equal(cr.modTime)
+		cr.lck.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := cr.load(); err != nil {
+			log.Println("certificate reload error: ", err)
+			continue
+		}
+		log.Println("certificate reloaded from", cr.certFile)
+	}
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lck.RLock()
+	defer cr.lck.RUnlock()
+	return cr.cert, nil
+}
+
+// newTLSConfig build the server tls config, clientCAFile enable the client certificates verification,
+// they are optional so clients can still use the other authentication methods
+func newTLSConfig(reloader *certReloader, clientCAFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if clientCAFile != "" {
+		caBytes, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no certificate found in %v", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// certAuthenticator authenticate the requests carrying a verified client certificate by its common name
+type certAuthenticator struct{}
+
+func (ca *certAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return nil, nil
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate has no common name")
+	}
+	return &Identity{Name: cert.Subject.CommonName, Method: "mtls"}, nil
+}
+
+// redirectToHTTPS answer every request with a redirect to the same url on the https address
+func redirectToHTTPS(httpsAddr string) http.HandlerFunc {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}